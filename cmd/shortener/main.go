@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"github.com/Evlushin/shorturl/internal/logger"
 	"github.com/Evlushin/shorturl/internal/repository"
 	"github.com/Evlushin/shorturl/internal/repository/file"
 	"github.com/Evlushin/shorturl/internal/repository/inmemory"
 	"github.com/Evlushin/shorturl/internal/repository/pg"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/Evlushin/shorturl/internal/config"
 	"github.com/Evlushin/shorturl/internal/handler"
@@ -26,15 +31,35 @@ func run() error {
 		return err
 	}
 
+	if cfg.Handlers.SecretKey == "" {
+		secretKey, err := config.RandomSecretKey()
+		if err != nil {
+			return fmt.Errorf("failed to generate secret key: %w", err)
+		}
+		cfg.Handlers.SecretKey = secretKey
+
+		if cfg.DatabaseDsn != "" || cfg.FileStorePath != "" {
+			logger.Log.Warn("SECRET_KEY is not set, a random one is used: auth cookies are invalidated on every restart " +
+				"and users lose access to their stored links")
+		}
+	}
+
 	store, err := NewRepository(&cfg)
 	if err != nil {
 		return err
 	}
 	defer store.Close()
 
-	shortenerService := service.NewShortener(store)
+	shortenerService, err := service.NewShortener(store, cfg.Service)
+	if err != nil {
+		return err
+	}
+	defer shortenerService.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	return handler.Serve(cfg.Handlers, shortenerService)
+	return handler.Serve(ctx, cfg.Handlers, shortenerService)
 }
 
 func NewRepository(cfg *config.Config) (repository.Repository, error) {
@@ -1,13 +1,20 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"flag"
 	handlersConfig "github.com/Evlushin/shorturl/internal/handler/config"
+	serviceConfig "github.com/Evlushin/shorturl/internal/service/config"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
 	Handlers      handlersConfig.Config
+	Service       serviceConfig.Config
 	LogLevel      string
 	FileStorePath string
 	DatabaseDsn   string
@@ -23,8 +30,47 @@ func GetConfig() Config {
 	//flag.StringVar(&cfg.DatabaseDsn, "d", "host=127.127.126.41 port=5432 dbname=shorturl user=shorturl password=shorturl connect_timeout=10 sslmode=prefer", "connection string")
 	flag.StringVar(&cfg.FileStorePath, "f", "", "address storage")
 	flag.StringVar(&cfg.DatabaseDsn, "d", "", "connection string")
+	flag.StringVar(&cfg.Handlers.SecretKey, "k", "", "secret key for signing auth cookies")
+	flag.StringVar(&cfg.Handlers.NotFoundTemplate, "not-found-template", "", "path to HTML template rendered for unknown links")
+	flag.StringVar(&cfg.Handlers.NotFoundRedirectURL, "not-found-redirect", "", "URL browsers are redirected to for unknown links")
+	flag.IntVar(&cfg.Handlers.RedirectCode, "redirect-code", 307, "default redirect status code of links: 301, 302, 303, 307 or 308")
+	flag.DurationVar(&cfg.Handlers.RedirectCacheMaxAge, "redirect-cache-max-age", 24*time.Hour, "how long clients may cache permanent redirects")
+	flag.BoolVar(&cfg.Handlers.ScheduledNotFound, "scheduled-not-found", false, "answer links not active yet with 404 instead of 425")
+	flag.StringVar(&cfg.Service.AliasPattern, "alias-pattern", `^[A-Za-z0-9_-]{3,64}$`, "pattern of user-chosen aliases")
+	flag.DurationVar(&cfg.Service.SweepInterval, "sweep-interval", time.Hour, "interval between purges of expired links, 0 disables purging")
+	flag.DurationVar(&cfg.Service.ExpiredRetention, "expired-retention", 24*time.Hour, "how long expired links are kept before purging")
+	flag.IntVar(&cfg.Service.PasswordMaxAttempts, "password-max-attempts", 5, "wrong password attempts allowed per link within the window")
+	flag.DurationVar(&cfg.Service.PasswordAttemptsWindow, "password-attempts-window", 15*time.Minute, "window for counting wrong password attempts")
+	flag.StringVar(&cfg.Service.IDGenerator, "id-generator", "random", "short id generator: random, sequence, obfuscated, words or hash")
+	flag.IntVar(&cfg.Service.IDLength, "id-length", 8, "length of random, obfuscated and hash short ids")
+	flag.StringVar(&cfg.Service.IDAlphabet, "id-alphabet", "", "alphabet of generated short ids, base62 by default")
+	flag.StringVar(&cfg.Service.IDSalt, "id-salt", "", "salt of obfuscated short ids and key of hash short ids, required by the hash generator")
+	flag.IntVar(&cfg.Service.IDWords, "id-words", 3, "number of words in word-based short ids")
+	flag.Uint64Var(&cfg.Service.IDSequenceStart, "id-sequence-start", 0, "offset added to the stored sequence by the sequence and obfuscated id generators")
+	flag.BoolVar(&cfg.Service.SortQueryParams, "sort-query-params", false, "sort query parameters when comparing URLs for duplicates")
+	stripQueryParams := flag.String("strip-query-params", "utm_*", "comma-separated query parameters, wildcards allowed, ignored when comparing URLs for duplicates")
+	allowedSchemes := flag.String("allowed-schemes", "http,https", "comma-separated schemes of URLs that can be shortened")
+	flag.IntVar(&cfg.Service.MaxURLLength, "max-url-length", 2048, "maximum length of URLs that can be shortened, 0 disables the limit")
+	allowedDomains := flag.String("allowed-domains", "", "comma-separated domains, wildcards allowed, that URLs are limited to")
+	deniedDomains := flag.String("denied-domains", "", "comma-separated domains, wildcards allowed, that can not be shortened")
+	flag.BoolVar(&cfg.Service.AllowPrivateHosts, "allow-private-hosts", false, "allow URLs pointing to loopback and private addresses")
+	shortDomains := flag.String("short-domains", "", "comma-separated base addresses of additional short domains, link IDs are scoped per domain")
+	aliasDomains := flag.String("alias-domains", "", "comma-separated hosts that also serve our short links")
+	flag.StringVar(&cfg.Service.BlocklistPath, "blocklist", "", "path to the blocklist file of malicious domains, URL prefixes and hashes")
+	flag.DurationVar(&cfg.Service.BlocklistReload, "blocklist-reload", time.Minute, "interval between checks of the blocklist file for changes, 0 disables reloading")
+	flag.BoolVar(&cfg.Service.BlocklistOnRedirect, "blocklist-on-redirect", true, "check links against the blocklist on redirect too")
+	flag.StringVar(&cfg.Service.GeoIPPath, "geoip", "", "path to the CSV file mapping networks to countries for link rules")
+	reservedAliases := flag.String("reserved-aliases", "api,ping", "comma-separated aliases that can not be chosen by users")
 	flag.Parse()
 
+	cfg.Service.ReservedAliases = splitList(*reservedAliases)
+	cfg.Service.StripQueryParams = splitList(*stripQueryParams)
+	cfg.Service.AllowedSchemes = splitList(*allowedSchemes)
+	cfg.Service.AllowedDomains = splitList(*allowedDomains)
+	cfg.Service.DeniedDomains = splitList(*deniedDomains)
+	cfg.Service.AliasDomains = splitList(*aliasDomains)
+	cfg.Handlers.ShortDomains = splitList(*shortDomains)
+
 	if serverAddr := os.Getenv("SERVER_ADDRESS"); serverAddr != "" {
 		cfg.Handlers.ServerAddr = serverAddr
 	}
@@ -45,5 +91,151 @@ func GetConfig() Config {
 		cfg.DatabaseDsn = databaseDsn
 	}
 
+	if secretKey := os.Getenv("SECRET_KEY"); secretKey != "" {
+		cfg.Handlers.SecretKey = secretKey
+	}
+
+	if notFoundTemplate := os.Getenv("NOT_FOUND_TEMPLATE"); notFoundTemplate != "" {
+		cfg.Handlers.NotFoundTemplate = notFoundTemplate
+	}
+
+	if notFoundRedirectURL := os.Getenv("NOT_FOUND_REDIRECT_URL"); notFoundRedirectURL != "" {
+		cfg.Handlers.NotFoundRedirectURL = notFoundRedirectURL
+	}
+
+	if redirectCode, err := strconv.Atoi(os.Getenv("REDIRECT_CODE")); err == nil {
+		cfg.Handlers.RedirectCode = redirectCode
+	}
+
+	if redirectCacheMaxAge, err := time.ParseDuration(os.Getenv("REDIRECT_CACHE_MAX_AGE")); err == nil {
+		cfg.Handlers.RedirectCacheMaxAge = redirectCacheMaxAge
+	}
+
+	if scheduledNotFound, err := strconv.ParseBool(os.Getenv("SCHEDULED_NOT_FOUND")); err == nil {
+		cfg.Handlers.ScheduledNotFound = scheduledNotFound
+	}
+
+	if aliasPattern := os.Getenv("ALIAS_PATTERN"); aliasPattern != "" {
+		cfg.Service.AliasPattern = aliasPattern
+	}
+
+	if reservedAliases := os.Getenv("RESERVED_ALIASES"); reservedAliases != "" {
+		cfg.Service.ReservedAliases = splitList(reservedAliases)
+	}
+
+	if sweepInterval, err := time.ParseDuration(os.Getenv("SWEEP_INTERVAL")); err == nil {
+		cfg.Service.SweepInterval = sweepInterval
+	}
+
+	if expiredRetention, err := time.ParseDuration(os.Getenv("EXPIRED_RETENTION")); err == nil {
+		cfg.Service.ExpiredRetention = expiredRetention
+	}
+
+	if passwordMaxAttempts, err := strconv.Atoi(os.Getenv("PASSWORD_MAX_ATTEMPTS")); err == nil {
+		cfg.Service.PasswordMaxAttempts = passwordMaxAttempts
+	}
+
+	if passwordAttemptsWindow, err := time.ParseDuration(os.Getenv("PASSWORD_ATTEMPTS_WINDOW")); err == nil {
+		cfg.Service.PasswordAttemptsWindow = passwordAttemptsWindow
+	}
+
+	if idGenerator := os.Getenv("ID_GENERATOR"); idGenerator != "" {
+		cfg.Service.IDGenerator = idGenerator
+	}
+
+	if idLength, err := strconv.Atoi(os.Getenv("ID_LENGTH")); err == nil {
+		cfg.Service.IDLength = idLength
+	}
+
+	if idAlphabet := os.Getenv("ID_ALPHABET"); idAlphabet != "" {
+		cfg.Service.IDAlphabet = idAlphabet
+	}
+
+	if idSalt := os.Getenv("ID_SALT"); idSalt != "" {
+		cfg.Service.IDSalt = idSalt
+	}
+
+	if idWords, err := strconv.Atoi(os.Getenv("ID_WORDS")); err == nil {
+		cfg.Service.IDWords = idWords
+	}
+
+	if idSequenceStart, err := strconv.ParseUint(os.Getenv("ID_SEQUENCE_START"), 10, 64); err == nil {
+		cfg.Service.IDSequenceStart = idSequenceStart
+	}
+
+	if sortQueryParams, err := strconv.ParseBool(os.Getenv("SORT_QUERY_PARAMS")); err == nil {
+		cfg.Service.SortQueryParams = sortQueryParams
+	}
+
+	if stripQueryParams, ok := os.LookupEnv("STRIP_QUERY_PARAMS"); ok {
+		cfg.Service.StripQueryParams = splitList(stripQueryParams)
+	}
+
+	if allowedSchemes := os.Getenv("ALLOWED_SCHEMES"); allowedSchemes != "" {
+		cfg.Service.AllowedSchemes = splitList(allowedSchemes)
+	}
+
+	if maxURLLength, err := strconv.Atoi(os.Getenv("MAX_URL_LENGTH")); err == nil {
+		cfg.Service.MaxURLLength = maxURLLength
+	}
+
+	if allowedDomains, ok := os.LookupEnv("ALLOWED_DOMAINS"); ok {
+		cfg.Service.AllowedDomains = splitList(allowedDomains)
+	}
+
+	if deniedDomains, ok := os.LookupEnv("DENIED_DOMAINS"); ok {
+		cfg.Service.DeniedDomains = splitList(deniedDomains)
+	}
+
+	if allowPrivateHosts, err := strconv.ParseBool(os.Getenv("ALLOW_PRIVATE_HOSTS")); err == nil {
+		cfg.Service.AllowPrivateHosts = allowPrivateHosts
+	}
+
+	if aliasDomains, ok := os.LookupEnv("ALIAS_DOMAINS"); ok {
+		cfg.Service.AliasDomains = splitList(aliasDomains)
+	}
+
+	if blocklistPath := os.Getenv("BLOCKLIST_PATH"); blocklistPath != "" {
+		cfg.Service.BlocklistPath = blocklistPath
+	}
+
+	if blocklistReload, err := time.ParseDuration(os.Getenv("BLOCKLIST_RELOAD")); err == nil {
+		cfg.Service.BlocklistReload = blocklistReload
+	}
+
+	if blocklistOnRedirect, err := strconv.ParseBool(os.Getenv("BLOCKLIST_ON_REDIRECT")); err == nil {
+		cfg.Service.BlocklistOnRedirect = blocklistOnRedirect
+	}
+
+	if geoIPPath := os.Getenv("GEOIP_PATH"); geoIPPath != "" {
+		cfg.Service.GeoIPPath = geoIPPath
+	}
+
+	if shortDomains, ok := os.LookupEnv("SHORT_DOMAINS"); ok {
+		cfg.Handlers.ShortDomains = splitList(shortDomains)
+	}
+
+	cfg.Service.BaseURL = cfg.Handlers.BaseAddr
+	cfg.Service.ShortDomains = cfg.Handlers.ShortDomains
+
 	return cfg
 }
+
+// RandomSecretKey makes up a key for runs without a configured one.
+func RandomSecretKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func splitList(s string) []string {
+	res := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
@@ -1,6 +1,15 @@
 package config
 
+import "time"
+
 type Config struct {
-	ServerAddr string
-	BaseAddr   string
+	ServerAddr          string
+	BaseAddr            string
+	ShortDomains        []string
+	SecretKey           string
+	NotFoundTemplate    string
+	NotFoundRedirectURL string
+	RedirectCode        int
+	RedirectCacheMaxAge time.Duration
+	ScheduledNotFound   bool
 }
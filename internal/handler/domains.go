@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/Evlushin/shorturl/internal/models"
+	"github.com/Evlushin/shorturl/internal/myerrors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// newDomains maps the short domains to their base addresses. The default domain,
+// the one of BaseAddr, is kept under the empty key, like in the stores.
+func newDomains(baseAddr string, shortDomains []string) map[string]string {
+	domains := map[string]string{"": baseAddr}
+	defaultKey := models.DomainKey(baseAddr)
+	for _, base := range shortDomains {
+		base = strings.TrimRight(strings.TrimSpace(base), "/")
+		key := models.DomainKey(base)
+		if key == "" || key == defaultKey {
+			continue
+		}
+		domains[key] = base
+	}
+
+	return domains
+}
+
+// hostDomain is the domain the request was sent to, unknown hosts get the default one.
+func (h *handlers) hostDomain(r *http.Request) string {
+	key := strings.ToLower(r.Host)
+	if _, ok := h.domains[key]; ok {
+		return key
+	}
+	return ""
+}
+
+// requestDomain is the domain chosen by the client, given as a host or a base address,
+// or the one the request was sent to.
+func (h *handlers) requestDomain(r *http.Request, chosen string) (string, error) {
+	if chosen == "" {
+		chosen = r.URL.Query().Get("domain")
+	}
+	if chosen == "" {
+		return h.hostDomain(r), nil
+	}
+
+	key := strings.ToLower(chosen)
+	if strings.Contains(key, "://") {
+		key = models.DomainKey(key)
+	}
+	if key == models.DomainKey(h.cfg.BaseAddr) {
+		return "", nil
+	}
+	if _, ok := h.domains[key]; !ok || key == "" {
+		return "", fmt.Errorf("%w : domain : %s", myerrors.ErrValidateShortenerInvalidRequest, chosen)
+	}
+
+	return key, nil
+}
+
+// shortURL builds the short link from the base address of its domain.
+func (h *handlers) shortURL(domain string, id string) string {
+	base, ok := h.domains[domain]
+	if !ok {
+		scheme := "http"
+		if u, err := url.Parse(h.cfg.BaseAddr); err == nil && u.Scheme != "" {
+			scheme = u.Scheme
+		}
+		base = scheme + "://" + domain
+	}
+
+	return fmt.Sprintf("%s/%s", base, id)
+}
@@ -13,13 +13,22 @@ import (
 	"github.com/Evlushin/shorturl/internal/myerrors"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
+	"html/template"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
-func Serve(cfg config.Config, shortener Shortener) error {
-	h := newHandlers(shortener, cfg)
+const shutdownTimeout = 10 * time.Second
+
+// Serve handles requests until ctx is done, then lets the requests in flight finish.
+func Serve(ctx context.Context, cfg config.Config, shortener Shortener) error {
+	h, err := newHandlers(shortener, cfg)
+	if err != nil {
+		return err
+	}
 	router := newRouter(h)
 
 	logger.Log.Info("Starting server", zap.String("addr", cfg.ServerAddr))
@@ -29,7 +38,23 @@ func Serve(cfg config.Config, shortener Shortener) error {
 		Handler: router,
 	}
 
-	return srv.ListenAndServe()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err = <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Log.Info("Shutting down server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	return srv.Shutdown(shutdownCtx)
 }
 
 func newRouter(h *handlers) *chi.Mux {
@@ -37,15 +62,27 @@ func newRouter(h *handlers) *chi.Mux {
 
 	r.Use(logger.RequestLogger)
 	r.Use(middleware.GzipMiddleware)
+	r.Use(middleware.AuthMiddleware(h.cfg.SecretKey))
 
 	r.Post("/", h.SetShortener)
 	r.Get("/{id}", h.GetShortener)
+	r.Get("/{id}/*", h.GetShortener)
+	r.Post("/{id}", h.UnlockShortener)
+	r.Post("/{id}/*", h.UnlockShortener)
 	r.Get("/ping", h.Ping)
 
 	r.Route("/api", func(r chi.Router) {
 		r.Route("/shorten", func(r chi.Router) {
 			r.Post("/", h.SetShortenerAPI)
 			r.Post("/batch", h.SetShortenerBatchAPI)
+			r.Get("/{id}", h.GetShortenerInfoAPI)
+			r.Get("/{id}/qr", h.GetShortenerQRAPI)
+			r.With(middleware.RequireAuthMiddleware(h.cfg.SecretKey)).Patch("/{id}", h.UpdateShortenerAPI)
+		})
+		r.Route("/user", func(r chi.Router) {
+			r.Use(middleware.RequireAuthMiddleware(h.cfg.SecretKey))
+			r.Get("/urls", h.GetUserShortenersAPI)
+			r.Delete("/urls", h.DeleteUserShortenersAPI)
 		})
 	})
 
@@ -55,42 +92,218 @@ func newRouter(h *handlers) *chi.Mux {
 type Shortener interface {
 	GetShortener(ctx context.Context, req *models.GetShortenerRequest) (*models.GetShortenerResponse, error)
 	SetShortener(ctx context.Context, req *models.SetShortenerRequest) (*models.SetShortenerResponse, error)
-	SetShortenerBatch(ctx context.Context, req []models.RequestBatch) ([]models.SetShortenerBatchRequest, error)
+	SetShortenerBatch(ctx context.Context, userID string, req []models.RequestBatch) ([]models.SetShortenerBatchRequest, error)
+	GetShortenerInfo(ctx context.Context, req *models.GetShortenerRequest) (*models.GetShortenerResponse, error)
+	GetUserShorteners(ctx context.Context, req *models.GetUserShortenersRequest) ([]models.UserShortener, error)
+	UpdateShortener(ctx context.Context, req *models.UpdateShortenerRequest) error
+	DeleteShortenerBatch(ctx context.Context, userID string, domain string, ids []string) error
 	Ping(ctx context.Context) error
 }
 
 type handlers struct {
-	shortener Shortener
-	cfg       config.Config
+	shortener        Shortener
+	cfg              config.Config
+	domains          map[string]string
+	notFoundTemplate *template.Template
 }
 
-func newHandlers(shortener Shortener, cfg config.Config) *handlers {
-	return &handlers{
-		shortener: shortener,
-		cfg:       cfg,
+func newHandlers(shortener Shortener, cfg config.Config) (*handlers, error) {
+	if cfg.RedirectCode == 0 {
+		cfg.RedirectCode = http.StatusTemporaryRedirect
+	}
+	if !models.IsRedirectCode(cfg.RedirectCode) {
+		return nil, fmt.Errorf("invalid redirect code: %d", cfg.RedirectCode)
+	}
+
+	notFoundTemplate, err := loadNotFoundTemplate(cfg.NotFoundTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load not found template: %w", err)
 	}
+
+	return &handlers{
+		shortener:        shortener,
+		cfg:              cfg,
+		domains:          newDomains(cfg.BaseAddr, cfg.ShortDomains),
+		notFoundTemplate: notFoundTemplate,
+	}, nil
+}
+
+func isGoneErr(err error) bool {
+	return errors.Is(err, myerrors.ErrGetShortenerDeleted) ||
+		errors.Is(err, myerrors.ErrGetShortenerExpired) ||
+		errors.Is(err, myerrors.ErrGetShortenerExhausted)
 }
 
 func (h *handlers) GetShortener(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	id := chi.URLParam(r, "id")
+	req := h.getShortenerRequest(r)
+	id := req.ID
 
-	resp, err := h.shortener.GetShortener(ctx, &models.GetShortenerRequest{
-		ID: id,
-	})
+	resp, err := h.shortener.GetShortener(ctx, req)
 	if err != nil {
-		if errors.Is(err, myerrors.ErrGetShortenerInvalidRequest) || errors.Is(err, myerrors.ErrValidateShortenerInvalidRequest) || errors.Is(err, myerrors.ErrGetShortenerNotFound) {
-			logger.Log.Debug("bad request", zap.Int("status", 400), zap.Error(err))
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if errors.Is(err, myerrors.ErrPasswordRequired) {
+			renderHTML(w, passwordTemplate, passwordPage{}, http.StatusOK)
 			return
 		}
+		h.writeRedirectError(w, r, id, err)
+		return
+	}
+
+	if req.Preview {
+		setVariantCookie(w, id, resp)
+		h.renderPreview(w, req, resp)
+		return
+	}
+
+	code := resp.RedirectCode
+	if code == 0 {
+		code = h.cfg.RedirectCode
+	}
+
+	setVariantCookie(w, id, resp)
+	w.Header().Set("Cache-Control", h.redirectCacheControl(code, resp))
+	w.Header().Set("Location", resp.URL)
+	w.WriteHeader(code)
+}
+
+// writeRedirectError answers a failed redirect to the link.
+func (h *handlers) writeRedirectError(w http.ResponseWriter, r *http.Request, id string, err error) {
+	switch {
+	case errors.Is(err, myerrors.ErrGetShortenerBlocked):
+		logger.Log.Debug("blocked", zap.Int("status", 451), zap.Error(err))
+		blockedError(w, r, id, err)
+	case isGoneErr(err):
+		logger.Log.Debug("gone", zap.Int("status", 410), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusGone)
+	case errors.Is(err, myerrors.ErrGetShortenerNotActive):
+		h.notActiveError(w, r, id, err)
+	case errors.Is(err, myerrors.ErrGetShortenerNotFound):
+		logger.Log.Debug("not found", zap.Int("status", 404), zap.Error(err))
+		h.linkError(w, r, id, err, http.StatusNotFound)
+	case errors.Is(err, myerrors.ErrGetShortenerInvalidRequest) || errors.Is(err, myerrors.ErrValidateShortenerInvalidRequest):
+		logger.Log.Debug("bad request", zap.Int("status", 400), zap.Error(err))
+		h.linkError(w, r, id, err, http.StatusBadRequest)
+	default:
 		logger.Log.Error("failed to get shortener", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// passthroughPath is the escaped rest of the short URL path after the link ID.
+func passthroughPath(r *http.Request) string {
+	_, rest, found := strings.Cut(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/")
+	if !found {
+		return ""
+	}
+	return "/" + rest
+}
+
+func acceptsHTML(r *http.Request) bool {
+	for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(mediaRange, ";")
+		if strings.TrimSpace(mediaType) == "text/html" {
+			return true
+		}
+	}
+	return false
+}
+
+// linkError answers browsers with the not found page or the fallback redirect and API clients with JSON.
+func (h *handlers) linkError(w http.ResponseWriter, r *http.Request, id string, err error, code int) {
+	if !acceptsHTML(r) {
+		errorJSON(w, err.Error(), code)
+		return
+	}
+
+	if h.cfg.NotFoundRedirectURL != "" && code != http.StatusTooEarly {
+		http.Redirect(w, r, h.cfg.NotFoundRedirectURL, http.StatusFound)
+		return
+	}
+
+	message := "The link you followed does not exist."
+	switch code {
+	case http.StatusBadRequest:
+		message = "The link you followed is malformed."
+	case http.StatusTooEarly:
+		message = "The link you followed is not active yet."
+	}
+
+	renderHTML(w, h.notFoundTemplate, errorPage{
+		Status:     code,
+		StatusText: http.StatusText(code),
+		Message:    message,
+		ID:         id,
+	}, code)
+}
+
+// notActiveError answers links scheduled for later with 425, or as unknown links when their existence is to be hidden.
+func (h *handlers) notActiveError(w http.ResponseWriter, r *http.Request, id string, err error) {
+	if h.cfg.ScheduledNotFound {
+		logger.Log.Debug("not found", zap.Int("status", 404), zap.Error(err))
+		h.linkError(w, r, id, err, http.StatusNotFound)
+		return
+	}
+
+	logger.Log.Debug("too early", zap.Int("status", 425), zap.Error(err))
+	w.Header().Set("Cache-Control", "no-store")
+	h.linkError(w, r, id, err, http.StatusTooEarly)
+}
+
+// blockedError warns browsers that the link leads to a blocked destination instead of redirecting.
+func blockedError(w http.ResponseWriter, r *http.Request, id string, err error) {
+	code := http.StatusUnavailableForLegalReasons
+	if !acceptsHTML(r) {
+		errorJSON(w, err.Error(), code)
+		return
+	}
+
+	renderHTML(w, blockedTemplate, errorPage{
+		Status:     code,
+		StatusText: http.StatusText(code),
+		Message:    "The link you followed leads to a destination that was reported as malicious, so it is not opened.",
+		ID:         id,
+	}, code)
+}
+
+func (h *handlers) UnlockShortener(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := r.ParseForm(); err != nil {
+		logger.Log.Debug("failed parse form", zap.Int("status", 400), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := h.getShortenerRequest(r)
+	req.Password = r.PostForm.Get("password")
+	id := req.ID
+
+	resp, err := h.shortener.GetShortener(ctx, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, myerrors.ErrPasswordRequired):
+			renderHTML(w, passwordTemplate, passwordPage{Error: "Enter the password."}, http.StatusOK)
+		case errors.Is(err, myerrors.ErrInvalidPassword):
+			logger.Log.Debug("forbidden", zap.Int("status", 403), zap.Error(err))
+			renderHTML(w, passwordTemplate, passwordPage{Error: "Wrong password."}, http.StatusForbidden)
+		case errors.Is(err, myerrors.ErrTooManyPasswordAttempts):
+			logger.Log.Debug("too many requests", zap.Int("status", 429), zap.Error(err))
+			renderHTML(w, passwordTemplate, passwordPage{Error: "Too many attempts, try again later."}, http.StatusTooManyRequests)
+		default:
+			h.writeRedirectError(w, r, id, err)
+		}
+		return
+	}
+
+	setVariantCookie(w, id, resp)
+	if req.Preview {
+		h.renderPreview(w, req, resp)
 		return
 	}
 
+	w.Header().Set("Cache-Control", "no-store")
 	w.Header().Set("Location", resp.URL)
-	w.WriteHeader(http.StatusTemporaryRedirect)
+	w.WriteHeader(http.StatusSeeOther)
 }
 
 func (h *handlers) Ping(w http.ResponseWriter, r *http.Request) {
@@ -114,8 +327,12 @@ func (h *handlers) SetShortener(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	userID, _ := middleware.UserIDFromContext(ctx)
+	domain := h.hostDomain(r)
 	resp, err := h.shortener.SetShortener(ctx, &models.SetShortenerRequest{
-		URL: string(body),
+		URL:    string(body),
+		Domain: domain,
+		UserID: userID,
 	})
 
 	isErrConflictURL := errors.Is(err, myerrors.ErrConflictURL)
@@ -131,7 +348,7 @@ func (h *handlers) SetShortener(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fullURL := fmt.Sprintf("%s/%s", h.cfg.BaseAddr, resp.ID)
+	fullURL := h.shortURL(domain, resp.ID)
 
 	w.Header().Set("Content-Type", "text/plain")
 	w.Header().Set("Content-Length", strconv.Itoa(len(fullURL)))
@@ -157,6 +374,29 @@ func errorJSON(w http.ResponseWriter, message string, code int) {
 	json.NewEncoder(w).Encode(errResp)
 }
 
+// badRequestJSON reports a rejected request, listing every rejected URL when the URL policy failed.
+func badRequestJSON(w http.ResponseWriter, err error) {
+	errResp := models.ErrorJSONResponse{
+		Message: err.Error(),
+	}
+
+	var policyErr *myerrors.URLPolicyError
+	if errors.As(err, &policyErr) {
+		errResp.Message = myerrors.ErrValidateShortenerInvalidRequest.Error()
+		for _, u := range policyErr.URLs {
+			errResp.Errors = append(errResp.Errors, models.ErrorURLEntry{
+				CorrelationID: u.CorrelationID,
+				URL:           u.URL,
+				Reason:        u.Reason,
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(errResp)
+}
+
 func (h *handlers) SetShortenerAPI(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	if contentType := r.Header.Get("Content-Type"); contentType != "application/json" {
@@ -172,15 +412,45 @@ func (h *handlers) SetShortenerAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	domain, err := h.requestDomain(r, req.Domain)
+	if err != nil {
+		logger.Log.Debug("bad request", zap.Int("status", 400), zap.Error(err))
+		badRequestJSON(w, err)
+		return
+	}
+
+	userID, _ := middleware.UserIDFromContext(ctx)
 	shortener, err := h.shortener.SetShortener(ctx, &models.SetShortenerRequest{
-		URL: req.URL,
+		URL:          req.URL,
+		Domain:       domain,
+		UserID:       userID,
+		Alias:        req.Alias,
+		ExpiresAt:    req.ExpiresAt,
+		TTLSeconds:   req.TTLSeconds,
+		ActiveFrom:   req.ActiveFrom,
+		Versions:     req.Versions,
+		Password:     req.Password,
+		MaxClicks:    req.MaxClicks,
+		RedirectCode: req.RedirectCode,
+		Passthrough:  req.Passthrough,
+		Targets:      req.Targets,
+		Sticky:       req.Sticky,
+		Rules:        req.Rules,
+		Title:        req.Title,
+		Description:  req.Description,
+		Tags:         req.Tags,
 	})
 
 	isErrConflictURL := errors.Is(err, myerrors.ErrConflictURL)
 	if err != nil && !isErrConflictURL {
+		if errors.Is(err, myerrors.ErrConflictAlias) {
+			logger.Log.Debug("conflict", zap.Int("status", 409), zap.Error(err))
+			errorJSON(w, err.Error(), http.StatusConflict)
+			return
+		}
 		if errors.Is(err, myerrors.ErrGetShortenerInvalidRequest) || errors.Is(err, myerrors.ErrValidateShortenerInvalidRequest) {
 			logger.Log.Debug("bad request", zap.Int("status", 400), zap.Error(err))
-			errorJSON(w, err.Error(), http.StatusBadRequest)
+			badRequestJSON(w, err)
 			return
 		}
 		logger.Log.Error("failed set shortener", zap.Error(err))
@@ -188,7 +458,7 @@ func (h *handlers) SetShortenerAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fullURL := fmt.Sprintf("%s/%s", h.cfg.BaseAddr, shortener.ID)
+	fullURL := h.shortURL(domain, shortener.ID)
 
 	resp := models.Response{
 		Result: fullURL,
@@ -231,13 +501,29 @@ func (h *handlers) SetShortenerBatchAPI(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	shorteners, err := h.shortener.SetShortenerBatch(ctx, req)
+	for i := range req {
+		domain, err := h.requestDomain(r, req[i].Domain)
+		if err != nil {
+			logger.Log.Debug("bad request", zap.Int("status", 400), zap.Error(err))
+			badRequestJSON(w, fmt.Errorf("%w : correlation_id : %s", err, req[i].CorrelationID))
+			return
+		}
+		req[i].Domain = domain
+	}
+
+	userID, _ := middleware.UserIDFromContext(ctx)
+	shorteners, err := h.shortener.SetShortenerBatch(ctx, userID, req)
 
 	isErrConflictURL := errors.Is(err, myerrors.ErrConflictURL)
 	if err != nil && !isErrConflictURL {
+		if errors.Is(err, myerrors.ErrConflictAlias) {
+			logger.Log.Debug("conflict", zap.Int("status", 409), zap.Error(err))
+			errorJSON(w, err.Error(), http.StatusConflict)
+			return
+		}
 		if errors.Is(err, myerrors.ErrGetShortenerInvalidRequest) || errors.Is(err, myerrors.ErrValidateShortenerInvalidRequest) {
 			logger.Log.Debug("bad request", zap.Int("status", 400), zap.Error(err))
-			errorJSON(w, err.Error(), http.StatusBadRequest)
+			badRequestJSON(w, err)
 			return
 		}
 		logger.Log.Error("failed set shortener", zap.Error(err))
@@ -247,7 +533,7 @@ func (h *handlers) SetShortenerBatchAPI(w http.ResponseWriter, r *http.Request)
 
 	var resp []models.ResponseBatch
 	for _, shortener := range shorteners {
-		fullURL := fmt.Sprintf("%s/%s", h.cfg.BaseAddr, shortener.ID)
+		fullURL := h.shortURL(shortener.Domain, shortener.ID)
 		resp = append(resp, models.ResponseBatch{
 			CorrelationID: shortener.CorrelationID,
 			ShortURL:      fullURL,
@@ -275,3 +561,250 @@ func (h *handlers) SetShortenerBatchAPI(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(status)
 	w.Write(jsonBytes)
 }
+
+func (h *handlers) GetUserShortenersAPI(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := middleware.UserIDFromContext(ctx)
+	if !ok {
+		errorJSON(w, myerrors.ErrUnauthorized.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	shorteners, err := h.shortener.GetUserShorteners(ctx, &models.GetUserShortenersRequest{
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, myerrors.ErrUnauthorized) {
+			errorJSON(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		logger.Log.Error("failed get user shorteners", zap.Error(err))
+		errorJSON(w, myerrors.ErrInternalServer.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(shorteners) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	resp := make([]models.ResponseUserURL, 0, len(shorteners))
+	for _, shortener := range shorteners {
+		resp = append(resp, models.ResponseUserURL{
+			ShortURL:    h.shortURL(shortener.Domain, shortener.ID),
+			OriginalURL: shortener.URL,
+		})
+	}
+
+	buf := new(bytes.Buffer)
+	err = json.NewEncoder(buf).Encode(resp)
+	if err != nil {
+		logger.Log.Error("failed json encode", zap.Error(err))
+		errorJSON(w, myerrors.ErrInternalServer.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonBytes := buf.Bytes()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(jsonBytes)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (h *handlers) DeleteUserShortenersAPI(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := middleware.UserIDFromContext(ctx)
+	if !ok {
+		errorJSON(w, myerrors.ErrUnauthorized.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if contentType := r.Header.Get("Content-Type"); contentType != "application/json" {
+		errorJSON(w, myerrors.ErrContentType.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var ids []string
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&ids); err != nil {
+		logger.Log.Debug("failed decode json", zap.Int("status", 400), zap.Error(err))
+		errorJSON(w, myerrors.ErrJSONDecode.Error(), http.StatusBadRequest)
+		return
+	}
+
+	domain, err := h.requestDomain(r, "")
+	if err != nil {
+		logger.Log.Debug("bad request", zap.Int("status", 400), zap.Error(err))
+		badRequestJSON(w, err)
+		return
+	}
+
+	if err := h.shortener.DeleteShortenerBatch(ctx, userID, domain, ids); err != nil {
+		logger.Log.Error("failed delete shorteners", zap.Error(err))
+		errorJSON(w, myerrors.ErrInternalServer.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *handlers) UpdateShortenerAPI(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := middleware.UserIDFromContext(ctx)
+	if !ok {
+		errorJSON(w, myerrors.ErrUnauthorized.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if contentType := r.Header.Get("Content-Type"); contentType != "application/json" {
+		errorJSON(w, myerrors.ErrContentType.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req models.Request
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&req); err != nil {
+		logger.Log.Debug("failed decode json", zap.Int("status", 400), zap.Error(err))
+		errorJSON(w, myerrors.ErrJSONDecode.Error(), http.StatusBadRequest)
+		return
+	}
+
+	domain, err := h.requestDomain(r, req.Domain)
+	if err != nil {
+		logger.Log.Debug("bad request", zap.Int("status", 400), zap.Error(err))
+		badRequestJSON(w, err)
+		return
+	}
+
+	updateReq := &models.UpdateShortenerRequest{
+		ID:     chi.URLParam(r, "id"),
+		Domain: domain,
+		URL:    req.URL,
+		UserID: userID,
+	}
+	err = h.shortener.UpdateShortener(ctx, updateReq)
+
+	isErrConflictURL := errors.Is(err, myerrors.ErrConflictURL)
+	if err != nil && !isErrConflictURL {
+		switch {
+		case errors.Is(err, myerrors.ErrGetShortenerInvalidRequest) || errors.Is(err, myerrors.ErrValidateShortenerInvalidRequest):
+			logger.Log.Debug("bad request", zap.Int("status", 400), zap.Error(err))
+			badRequestJSON(w, err)
+		case errors.Is(err, myerrors.ErrForbidden):
+			logger.Log.Debug("forbidden", zap.Int("status", 403), zap.Error(err))
+			errorJSON(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, myerrors.ErrGetShortenerNotFound):
+			logger.Log.Debug("not found", zap.Int("status", 404), zap.Error(err))
+			errorJSON(w, err.Error(), http.StatusNotFound)
+		case isGoneErr(err):
+			logger.Log.Debug("gone", zap.Int("status", 410), zap.Error(err))
+			errorJSON(w, err.Error(), http.StatusGone)
+		default:
+			logger.Log.Error("failed update shortener", zap.Error(err))
+			errorJSON(w, myerrors.ErrInternalServer.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	id := updateReq.ID
+	status := http.StatusOK
+	if isErrConflictURL {
+		id = updateReq.ExistingID
+		status = http.StatusConflict
+	}
+
+	resp := models.Response{
+		Result: h.shortURL(domain, id),
+	}
+
+	buf := new(bytes.Buffer)
+	err = json.NewEncoder(buf).Encode(resp)
+	if err != nil {
+		logger.Log.Error("failed json encode", zap.Error(err))
+		errorJSON(w, myerrors.ErrInternalServer.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonBytes := buf.Bytes()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(jsonBytes)))
+	w.WriteHeader(status)
+	w.Write(jsonBytes)
+}
+
+func (h *handlers) GetShortenerInfoAPI(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	domain, err := h.requestDomain(r, "")
+	if err != nil {
+		logger.Log.Debug("bad request", zap.Int("status", 400), zap.Error(err))
+		errorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	shortener, err := h.shortener.GetShortenerInfo(ctx, &models.GetShortenerRequest{
+		ID:     id,
+		Domain: domain,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, myerrors.ErrGetShortenerInvalidRequest) || errors.Is(err, myerrors.ErrValidateShortenerInvalidRequest):
+			logger.Log.Debug("bad request", zap.Int("status", 400), zap.Error(err))
+			errorJSON(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, myerrors.ErrGetShortenerNotFound):
+			logger.Log.Debug("not found", zap.Int("status", 404), zap.Error(err))
+			errorJSON(w, err.Error(), http.StatusNotFound)
+		default:
+			logger.Log.Error("failed get shortener info", zap.Error(err))
+			errorJSON(w, myerrors.ErrInternalServer.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	resp := models.ResponseInfo{
+		ShortURL:     h.shortURL(domain, id),
+		OriginalURL:  shortener.URL,
+		Title:        shortener.Title,
+		Description:  shortener.Description,
+		Tags:         shortener.Tags,
+		Owner:        shortener.UserID,
+		RedirectCode: shortener.RedirectCode,
+		Passthrough:  shortener.Passthrough,
+		Targets:      shortener.Targets,
+		Sticky:       shortener.Sticky,
+		Rules:        shortener.Rules,
+		ActiveFrom:   shortener.ActiveFrom,
+		Versions:     shortener.Versions,
+		Clicks:       shortener.Clicks,
+		Status:       shortener.Status,
+	}
+	if resp.Tags == nil {
+		resp.Tags = []string{}
+	}
+	if !shortener.CreatedAt.IsZero() {
+		resp.CreatedAt = &shortener.CreatedAt
+	}
+
+	// the target of a password-protected link is only disclosed to its owner
+	if userID, _ := middleware.UserIDFromContext(ctx); shortener.PasswordHash != "" && shortener.UserID != userID {
+		resp.OriginalURL = ""
+		resp.Targets = nil
+		resp.Rules = nil
+		resp.Versions = nil
+	}
+
+	buf := new(bytes.Buffer)
+	err = json.NewEncoder(buf).Encode(resp)
+	if err != nil {
+		logger.Log.Error("failed json encode", zap.Error(err))
+		errorJSON(w, myerrors.ErrInternalServer.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonBytes := buf.Bytes()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(jsonBytes)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
@@ -1,27 +1,66 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/Evlushin/shorturl/internal/config"
+	"github.com/Evlushin/shorturl/internal/middleware"
+	"github.com/Evlushin/shorturl/internal/models"
 	"github.com/Evlushin/shorturl/internal/repository/inmemory"
 	"github.com/Evlushin/shorturl/internal/service"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"image"
+	"image/png"
 	"io"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func getHandlersMemory() *handlers {
 	cfg := config.Config{}
 	cfg.Handlers.ServerAddr = "localhost:8080"
+	cfg.Handlers.SecretKey = "test-secret-key"
+	cfg.Service.AliasPattern = `^[A-Za-z0-9_-]{3,64}$`
+	cfg.Service.ReservedAliases = []string{"api", "ping"}
+	cfg.Service.StripQueryParams = []string{"utm_*"}
+	cfg.Service.PasswordMaxAttempts = 3
+	cfg.Service.PasswordAttemptsWindow = time.Minute
 	store, _ := inmemory.NewStore(&cfg)
-	shortenerService := service.NewShortener(store)
-	return newHandlers(shortenerService, cfg.Handlers)
+	shortenerService, _ := service.NewShortener(store, cfg.Service)
+	h, _ := newHandlers(shortenerService, cfg.Handlers)
+	return h
+}
+
+func TestServe_Shutdown(t *testing.T) {
+	h := getHandlersMemory()
+	cfg := h.cfg
+	cfg.ServerAddr = "127.0.0.1:0"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- Serve(ctx, cfg, h.shortener)
+	}()
+	cancel()
+
+	select {
+	case err := <-errCh:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after the context was done")
+	}
 }
 
 func Test_handlers_SetShortener(t *testing.T) {
@@ -235,3 +274,1200 @@ func Test_handlers_SetShortenerBatchAPI(t *testing.T) {
 		})
 	}
 }
+
+func Test_handlers_GetUserShortenersAPI(t *testing.T) {
+	h := getHandlersMemory()
+
+	ts := httptest.NewServer(newRouter(h))
+	defer ts.Close()
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := &http.Client{Jar: jar}
+
+	resUnauthorized, err := client.Get(ts.URL + "/api/user/urls")
+	require.NoError(t, err)
+	resUnauthorized.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resUnauthorized.StatusCode)
+
+	resEmpty, err := client.Get(ts.URL + "/api/user/urls")
+	require.NoError(t, err)
+	resEmpty.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resEmpty.StatusCode)
+
+	resSet, err := client.Post(ts.URL+"/", "text/plain", strings.NewReader("https://practicum.yandex.ru/"))
+	require.NoError(t, err)
+	resBodySet, err := io.ReadAll(resSet.Body)
+	require.NoError(t, err)
+	resSet.Body.Close()
+	require.Equal(t, http.StatusCreated, resSet.StatusCode)
+
+	resGet, err := client.Get(ts.URL + "/api/user/urls")
+	require.NoError(t, err)
+	defer resGet.Body.Close()
+
+	var response []models.ResponseUserURL
+	err = json.NewDecoder(resGet.Body).Decode(&response)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resGet.StatusCode)
+	assert.Equal(t, []models.ResponseUserURL{
+		{ShortURL: string(resBodySet), OriginalURL: "https://practicum.yandex.ru/"},
+	}, response)
+
+	requestInvalid, err := http.NewRequest(http.MethodGet, ts.URL+"/api/user/urls", nil)
+	require.NoError(t, err)
+	requestInvalid.AddCookie(&http.Cookie{Name: middleware.AuthCookieName, Value: "invalid"})
+	resInvalid, err := ts.Client().Do(requestInvalid)
+	require.NoError(t, err)
+	resInvalid.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resInvalid.StatusCode)
+}
+
+func Test_handlers_DeleteUserShortenersAPI(t *testing.T) {
+	h := getHandlersMemory()
+
+	ts := httptest.NewServer(newRouter(h))
+	defer ts.Close()
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := &http.Client{
+		Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resSet, err := client.Post(ts.URL+"/", "text/plain", strings.NewReader("https://practicum.yandex.ru/"))
+	require.NoError(t, err)
+	resBodySet, err := io.ReadAll(resSet.Body)
+	require.NoError(t, err)
+	resSet.Body.Close()
+
+	parseURL, err := url.Parse(string(resBodySet))
+	require.NoError(t, err)
+	id := strings.TrimPrefix(parseURL.Path, "/")
+
+	requestDelete, err := http.NewRequest(http.MethodDelete, ts.URL+"/api/user/urls", strings.NewReader(`["`+id+`"]`))
+	require.NoError(t, err)
+	requestDelete.Header.Add("Content-Type", "application/json")
+	resDelete, err := client.Do(requestDelete)
+	require.NoError(t, err)
+	resDelete.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resDelete.StatusCode)
+
+	assert.Eventually(t, func() bool {
+		resGet, err := client.Get(ts.URL + "/" + id)
+		if err != nil {
+			return false
+		}
+		resGet.Body.Close()
+		return resGet.StatusCode == http.StatusGone
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func Test_handlers_SetShortenerAPIAlias(t *testing.T) {
+	h := getHandlersMemory()
+
+	ts := httptest.NewServer(newRouter(h))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		request  string
+		wantCode int
+	}{
+		{
+			name:     "custom alias",
+			request:  `{"url": "https://practicum.yandex.ru", "alias": "spring-sale"}`,
+			wantCode: http.StatusCreated,
+		},
+		{
+			name:     "alias already taken",
+			request:  `{"url": "https://www.google.com", "alias": "spring-sale"}`,
+			wantCode: http.StatusConflict,
+		},
+		{
+			name:     "reserved alias",
+			request:  `{"url": "https://www.google.com", "alias": "api"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "alias does not match pattern",
+			request:  `{"url": "https://www.google.com", "alias": "spring sale"}`,
+			wantCode: http.StatusBadRequest,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resSet, err := ts.Client().Post(ts.URL+"/api/shorten", "application/json", strings.NewReader(test.request))
+			require.NoError(t, err)
+			resSet.Body.Close()
+
+			assert.Equal(t, test.wantCode, resSet.StatusCode)
+		})
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resGet, err := client.Get(ts.URL + "/spring-sale")
+	require.NoError(t, err)
+	resGet.Body.Close()
+
+	assert.Equal(t, http.StatusTemporaryRedirect, resGet.StatusCode)
+	assert.Equal(t, "https://practicum.yandex.ru", resGet.Header.Get("Location"))
+}
+
+func Test_handlers_SetShortenerAliasOnShortenedURL(t *testing.T) {
+	h := getHandlersMemory()
+
+	ts := httptest.NewServer(newRouter(h))
+	defer ts.Close()
+
+	shorten := func(request string) int {
+		res, err := ts.Client().Post(ts.URL+"/api/shorten", "application/json", strings.NewReader(request))
+		require.NoError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	require.Equal(t, http.StatusCreated, shorten(`{"url": "https://example.com/sale"}`))
+	assert.Equal(t, http.StatusCreated, shorten(`{"url": "https://example.com/sale", "alias": "spring-sale"}`))
+	// the alias is not shared with later requests for the URL either
+	assert.Equal(t, http.StatusConflict, shorten(`{"url": "https://example.com/sale"}`))
+
+	request := `[
+		{"correlation_id": "1", "original_url": "https://example.com/sale"},
+		{"correlation_id": "2", "original_url": "https://example.com/sale", "alias": "autumn-sale"}
+	]`
+	plain := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	res, err := plain.Post(ts.URL+"/api/shorten/batch", "application/json", strings.NewReader(request))
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+	var response []models.ResponseBatch
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&response))
+	require.Len(t, response, 2)
+	assert.NotEqual(t, response[0].ShortURL, response[1].ShortURL)
+	assert.True(t, strings.HasSuffix(response[1].ShortURL, "/autumn-sale"))
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	for _, alias := range []string{"spring-sale", "autumn-sale"} {
+		resGet, err := client.Get(ts.URL + "/" + alias)
+		require.NoError(t, err)
+		resGet.Body.Close()
+
+		assert.Equal(t, http.StatusTemporaryRedirect, resGet.StatusCode, alias)
+		assert.Equal(t, "https://example.com/sale", resGet.Header.Get("Location"), alias)
+	}
+}
+
+func Test_handlers_GetShortenerExpired(t *testing.T) {
+	h := getHandlersMemory()
+
+	ts := httptest.NewServer(newRouter(h))
+	defer ts.Close()
+
+	resInvalid, err := ts.Client().Post(ts.URL+"/api/shorten", "application/json",
+		strings.NewReader(`{"url": "https://practicum.yandex.ru", "ttl_seconds": -1}`))
+	require.NoError(t, err)
+	resInvalid.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resInvalid.StatusCode)
+
+	resSet, err := ts.Client().Post(ts.URL+"/api/shorten", "application/json",
+		strings.NewReader(`{"url": "https://practicum.yandex.ru", "ttl_seconds": 1}`))
+	require.NoError(t, err)
+	defer resSet.Body.Close()
+	require.Equal(t, http.StatusCreated, resSet.StatusCode)
+
+	var response models.Response
+	require.NoError(t, json.NewDecoder(resSet.Body).Decode(&response))
+	parseURL, err := url.Parse(response.Result)
+	require.NoError(t, err)
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resGet, err := client.Get(ts.URL + parseURL.Path)
+	require.NoError(t, err)
+	resGet.Body.Close()
+	assert.Equal(t, http.StatusTemporaryRedirect, resGet.StatusCode)
+
+	assert.Eventually(t, func() bool {
+		resGet, err := client.Get(ts.URL + parseURL.Path)
+		if err != nil {
+			return false
+		}
+		resGet.Body.Close()
+		return resGet.StatusCode == http.StatusGone
+	}, 3*time.Second, 100*time.Millisecond)
+}
+
+func Test_handlers_UnlockShortener(t *testing.T) {
+	h := getHandlersMemory()
+
+	ts := httptest.NewServer(newRouter(h))
+	defer ts.Close()
+
+	resSet, err := ts.Client().Post(ts.URL+"/api/shorten", "application/json",
+		strings.NewReader(`{"url": "https://practicum.yandex.ru", "alias": "secret-doc", "password": "qwerty"}`))
+	require.NoError(t, err)
+	resSet.Body.Close()
+	require.Equal(t, http.StatusCreated, resSet.StatusCode)
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resGet, err := client.Get(ts.URL + "/secret-doc")
+	require.NoError(t, err)
+	resGet.Body.Close()
+	assert.Equal(t, http.StatusOK, resGet.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", resGet.Header.Get("Content-Type"))
+
+	tests := []struct {
+		name         string
+		password     string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "correct password",
+			password:     "qwerty",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "https://practicum.yandex.ru",
+		},
+		{name: "wrong password #1", password: "123456", wantCode: http.StatusForbidden},
+		{name: "wrong password #2", password: "123456", wantCode: http.StatusForbidden},
+		{name: "wrong password #3", password: "123456", wantCode: http.StatusForbidden},
+		{name: "rate limited", password: "qwerty", wantCode: http.StatusTooManyRequests},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resPost, err := client.PostForm(ts.URL+"/secret-doc", url.Values{"password": {test.password}})
+			require.NoError(t, err)
+			resPost.Body.Close()
+
+			assert.Equal(t, test.wantCode, resPost.StatusCode)
+			assert.Equal(t, test.wantLocation, resPost.Header.Get("Location"))
+		})
+	}
+}
+
+func Test_handlers_GetShortenerMaxClicks(t *testing.T) {
+	h := getHandlersMemory()
+
+	ts := httptest.NewServer(newRouter(h))
+	defer ts.Close()
+
+	const (
+		maxClicks = 5
+		requests  = 20
+	)
+
+	resSet, err := ts.Client().Post(ts.URL+"/api/shorten", "application/json",
+		strings.NewReader(`{"url": "https://practicum.yandex.ru", "alias": "one-time", "max_clicks": 5}`))
+	require.NoError(t, err)
+	resSet.Body.Close()
+	require.Equal(t, http.StatusCreated, resSet.StatusCode)
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	codes := make(chan int, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resGet, err := client.Get(ts.URL + "/one-time")
+			if err != nil {
+				codes <- 0
+				return
+			}
+			resGet.Body.Close()
+			codes <- resGet.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	counts := make(map[int]int)
+	for code := range codes {
+		counts[code]++
+	}
+
+	assert.Equal(t, map[int]int{
+		http.StatusTemporaryRedirect: maxClicks,
+		http.StatusGone:              requests - maxClicks,
+	}, counts)
+}
+
+func Test_handlers_UpdateShortenerAPI(t *testing.T) {
+	h := getHandlersMemory()
+
+	ts := httptest.NewServer(newRouter(h))
+	defer ts.Close()
+
+	newClient := func() *http.Client {
+		jar, err := cookiejar.New(nil)
+		require.NoError(t, err)
+		return &http.Client{
+			Jar: jar,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+	owner := newClient()
+	stranger := newClient()
+
+	for _, body := range []string{
+		`{"url": "https://practicum.yandex.ru", "alias": "flyer"}`,
+		`{"url": "https://www.google.com"}`,
+	} {
+		resSet, err := owner.Post(ts.URL+"/api/shorten", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		resSet.Body.Close()
+		require.Equal(t, http.StatusCreated, resSet.StatusCode)
+	}
+	resStranger, err := stranger.Get(ts.URL + "/flyer")
+	require.NoError(t, err)
+	resStranger.Body.Close()
+
+	tests := []struct {
+		name     string
+		client   *http.Client
+		request  string
+		wantCode int
+	}{
+		{name: "without cookie", client: ts.Client(), request: `{"url": "https://ya.ru"}`, wantCode: http.StatusUnauthorized},
+		{name: "not owner", client: stranger, request: `{"url": "https://ya.ru"}`, wantCode: http.StatusForbidden},
+		{name: "invalid url", client: owner, request: `{"url": "ya"}`, wantCode: http.StatusBadRequest},
+		{name: "url of a generated link", client: owner, request: `{"url": "https://www.google.com"}`, wantCode: http.StatusOK},
+		{name: "new target", client: owner, request: `{"url": "https://ya.ru"}`, wantCode: http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requestPatch, err := http.NewRequest(http.MethodPatch, ts.URL+"/api/shorten/flyer", strings.NewReader(test.request))
+			require.NoError(t, err)
+			requestPatch.Header.Add("Content-Type", "application/json")
+			resPatch, err := test.client.Do(requestPatch)
+			require.NoError(t, err)
+			resPatch.Body.Close()
+
+			assert.Equal(t, test.wantCode, resPatch.StatusCode)
+		})
+	}
+
+	resGet, err := owner.Get(ts.URL + "/flyer")
+	require.NoError(t, err)
+	resGet.Body.Close()
+	assert.Equal(t, "https://ya.ru", resGet.Header.Get("Location"))
+
+	// generated links are shared, so one of them cannot take the URL of another
+	resSet, err := owner.Post(ts.URL+"/api/shorten", "application/json", strings.NewReader(`{"url": "https://www.bing.com"}`))
+	require.NoError(t, err)
+	defer resSet.Body.Close()
+	require.Equal(t, http.StatusCreated, resSet.StatusCode)
+
+	var response models.Response
+	require.NoError(t, json.NewDecoder(resSet.Body).Decode(&response))
+	requestPatch, err := http.NewRequest(http.MethodPatch, ts.URL+"/api/shorten/"+path.Base(response.Result),
+		strings.NewReader(`{"url": "https://www.google.com"}`))
+	require.NoError(t, err)
+	requestPatch.Header.Add("Content-Type", "application/json")
+	resPatch, err := owner.Do(requestPatch)
+	require.NoError(t, err)
+	resPatch.Body.Close()
+	assert.Equal(t, http.StatusConflict, resPatch.StatusCode)
+}
+
+func Test_handlers_GetShortenerInfoAPI(t *testing.T) {
+	h := getHandlersMemory()
+
+	ts := httptest.NewServer(newRouter(h))
+	defer ts.Close()
+
+	resSet, err := ts.Client().Post(ts.URL+"/api/shorten", "application/json", strings.NewReader(`{
+		"url": "https://practicum.yandex.ru",
+		"alias": "course",
+		"title": "Go course",
+		"description": "Advanced Go developer",
+		"tags": ["go", " education ", "go"]
+	}`))
+	require.NoError(t, err)
+	resSet.Body.Close()
+	require.Equal(t, http.StatusCreated, resSet.StatusCode)
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resRedirect, err := client.Get(ts.URL + "/course")
+	require.NoError(t, err)
+	resRedirect.Body.Close()
+
+	resGet, err := ts.Client().Get(ts.URL + "/api/shorten/course")
+	require.NoError(t, err)
+	defer resGet.Body.Close()
+
+	var response models.ResponseInfo
+	require.NoError(t, json.NewDecoder(resGet.Body).Decode(&response))
+
+	assert.Equal(t, http.StatusOK, resGet.StatusCode)
+	assert.Equal(t, "https://practicum.yandex.ru", response.OriginalURL)
+	assert.Equal(t, "Go course", response.Title)
+	assert.Equal(t, "Advanced Go developer", response.Description)
+	assert.Equal(t, []string{"go", "education"}, response.Tags)
+	assert.Equal(t, int64(1), response.Clicks)
+	assert.Equal(t, models.StatusActive, response.Status)
+	assert.NotEmpty(t, response.Owner)
+	require.NotNil(t, response.CreatedAt)
+	assert.WithinDuration(t, time.Now(), *response.CreatedAt, time.Minute)
+
+	resNotFound, err := ts.Client().Get(ts.URL + "/api/shorten/unknown")
+	require.NoError(t, err)
+	resNotFound.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resNotFound.StatusCode)
+}
+
+func Test_handlers_GetShortenerNotFound(t *testing.T) {
+	h := getHandlersMemory()
+
+	ts := httptest.NewServer(newRouter(h))
+	defer ts.Close()
+
+	type want struct {
+		code        int
+		contentType string
+	}
+	tests := []struct {
+		name   string
+		path   string
+		accept string
+		want   want
+	}{
+		{
+			name: "unknown id for API client",
+			path: "/AbCd1234",
+			want: want{code: http.StatusNotFound, contentType: "application/json"},
+		},
+		{
+			name:   "unknown id for browser",
+			path:   "/AbCd1234",
+			accept: "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8",
+			want:   want{code: http.StatusNotFound, contentType: "text/html; charset=utf-8"},
+		},
+		{
+			name: "malformed id for API client",
+			path: "/a!",
+			want: want{code: http.StatusBadRequest, contentType: "application/json"},
+		},
+		{
+			name:   "malformed id for browser",
+			path:   "/a!",
+			accept: "text/html",
+			want:   want{code: http.StatusBadRequest, contentType: "text/html; charset=utf-8"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requestGet, err := http.NewRequest(http.MethodGet, ts.URL+test.path, nil)
+			require.NoError(t, err)
+			requestGet.Header.Set("Accept", test.accept)
+			resGet, err := ts.Client().Do(requestGet)
+			require.NoError(t, err)
+			resGet.Body.Close()
+
+			assert.Equal(t, test.want.code, resGet.StatusCode)
+			assert.Equal(t, test.want.contentType, resGet.Header.Get("Content-Type"))
+		})
+	}
+
+	h.cfg.NotFoundRedirectURL = "https://example.com/404"
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	requestGet, err := http.NewRequest(http.MethodGet, ts.URL+"/AbCd1234", nil)
+	require.NoError(t, err)
+	requestGet.Header.Set("Accept", "text/html")
+	resGet, err := client.Do(requestGet)
+	require.NoError(t, err)
+	resGet.Body.Close()
+
+	assert.Equal(t, http.StatusFound, resGet.StatusCode)
+	assert.Equal(t, "https://example.com/404", resGet.Header.Get("Location"))
+}
+
+func Test_handlers_SetShortenerAPICanonicalURL(t *testing.T) {
+	h := getHandlersMemory()
+
+	ts := httptest.NewServer(newRouter(h))
+	defer ts.Close()
+
+	// conflict responses are not gzip-encoded, so the client does not ask for gzip
+	plain := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	shorten := func(u string) (int, string) {
+		res, err := plain.Post(ts.URL+"/api/shorten", "application/json", strings.NewReader(`{"url": "`+u+`"}`))
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		var response models.Response
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&response))
+		return res.StatusCode, response.Result
+	}
+
+	code, first := shorten("HTTP://Example.com:80?utm_source=mail")
+	require.Equal(t, http.StatusCreated, code)
+
+	code, second := shorten("http://example.com/")
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Equal(t, first, second)
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resGet, err := client.Get(ts.URL + first)
+	require.NoError(t, err)
+	resGet.Body.Close()
+
+	assert.Equal(t, http.StatusTemporaryRedirect, resGet.StatusCode)
+	assert.Equal(t, "HTTP://Example.com:80?utm_source=mail", resGet.Header.Get("Location"))
+}
+
+func Test_handlers_SetShortenerBatchAPIRejectedURLs(t *testing.T) {
+	h := getHandlersMemory()
+
+	ts := httptest.NewServer(newRouter(h))
+	defer ts.Close()
+
+	request := `[
+		{"correlation_id": "1", "original_url": "https://practicum.yandex.ru"},
+		{"correlation_id": "2", "original_url": "javascript:alert(1)"},
+		{"correlation_id": "3", "original_url": "http://127.0.0.1/admin"}
+	]`
+	plain := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	res, err := plain.Post(ts.URL+"/api/shorten/batch", "application/json", strings.NewReader(request))
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	var response models.ErrorJSONResponse
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&response))
+	assert.Equal(t, []models.ErrorURLEntry{
+		{CorrelationID: "2", URL: "javascript:alert(1)", Reason: service.ReasonSchemeNotAllowed},
+		{CorrelationID: "3", URL: "http://127.0.0.1/admin", Reason: service.ReasonPrivateAddress},
+	}, response.Errors)
+}
+
+func Test_handlers_BlockedURL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("domain evil.example\n"), 0644))
+
+	cfg := config.Config{}
+	cfg.Handlers.SecretKey = "test-secret-key"
+	cfg.Service.BlocklistPath = path
+	cfg.Service.BlocklistReload = 10 * time.Millisecond
+	cfg.Service.BlocklistOnRedirect = true
+	store, _ := inmemory.NewStore(&cfg)
+	shortenerService, err := service.NewShortener(store, cfg.Service)
+	require.NoError(t, err)
+	defer shortenerService.Close()
+	h, _ := newHandlers(shortenerService, cfg.Handlers)
+
+	ts := httptest.NewServer(newRouter(h))
+	defer ts.Close()
+
+	plain := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	res, err := plain.Post(ts.URL+"/api/shorten", "application/json", strings.NewReader(`{"url": "https://www.evil.example/"}`))
+	require.NoError(t, err)
+	var errResp models.ErrorJSONResponse
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&errResp))
+	res.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	require.Len(t, errResp.Errors, 1)
+	assert.Equal(t, service.ReasonBlocked, errResp.Errors[0].Reason)
+
+	res, err = plain.Post(ts.URL+"/api/shorten", "application/json", strings.NewReader(`{"url": "https://later.example/"}`))
+	require.NoError(t, err)
+	var resp models.Response
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&resp))
+	res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	require.NoError(t, os.WriteFile(path, []byte("domain evil.example\ndomain later.example\n"), 0644))
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	require.Eventually(t, func() bool {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+resp.Result, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", "text/html")
+
+		resGet, err := client.Do(req)
+		require.NoError(t, err)
+		resGet.Body.Close()
+
+		return resGet.StatusCode == http.StatusUnavailableForLegalReasons
+	}, time.Second, 20*time.Millisecond)
+}
+
+func Test_handlers_ShortDomains(t *testing.T) {
+	cfg := config.Config{}
+	cfg.Handlers.BaseAddr = "http://short.example"
+	cfg.Handlers.ShortDomains = []string{"https://brand.example"}
+	cfg.Handlers.SecretKey = "test-secret-key"
+	cfg.Service.AliasPattern = `^[A-Za-z0-9_-]{3,64}$`
+	cfg.Service.BaseURL = cfg.Handlers.BaseAddr
+	cfg.Service.ShortDomains = cfg.Handlers.ShortDomains
+	store, _ := inmemory.NewStore(&cfg)
+	shortenerService, _ := service.NewShortener(store, cfg.Service)
+	defer shortenerService.Close()
+	h, _ := newHandlers(shortenerService, cfg.Handlers)
+
+	ts := httptest.NewServer(newRouter(h))
+	defer ts.Close()
+
+	plain := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	shorten := func(request string) (int, string) {
+		res, err := plain.Post(ts.URL+"/api/shorten", "application/json", strings.NewReader(request))
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		var response models.Response
+		json.NewDecoder(res.Body).Decode(&response)
+		return res.StatusCode, response.Result
+	}
+
+	code, result := shorten(`{"url": "https://example.com/default", "alias": "promo"}`)
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, "http://short.example/promo", result)
+
+	code, result = shorten(`{"url": "https://example.com/brand", "alias": "promo", "domain": "brand.example"}`)
+	assert.Equal(t, http.StatusCreated, code, "the same ID is free on another domain")
+	assert.Equal(t, "https://brand.example/promo", result)
+
+	code, _ = shorten(`{"url": "https://example.com/other", "domain": "unknown.example"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	for host, want := range map[string]string{
+		"short.example": "https://example.com/default",
+		"brand.example": "https://example.com/brand",
+	} {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/promo", nil)
+		require.NoError(t, err)
+		req.Host = host
+
+		res, err := client.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+
+		assert.Equal(t, http.StatusTemporaryRedirect, res.StatusCode)
+		assert.Equal(t, want, res.Header.Get("Location"), host)
+	}
+
+	res, err := plain.Post(ts.URL+"/api/shorten/batch", "application/json", strings.NewReader(`[
+		{"correlation_id": "1", "original_url": "https://example.com/1"},
+		{"correlation_id": "2", "original_url": "https://example.com/2", "domain": "https://brand.example"}
+	]`))
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	var batch []models.ResponseBatch
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&batch))
+	require.Len(t, batch, 2)
+	assert.True(t, strings.HasPrefix(batch[0].ShortURL, "http://short.example/"))
+	assert.True(t, strings.HasPrefix(batch[1].ShortURL, "https://brand.example/"))
+}
+
+func Test_handlers_GetShortenerRedirectCode(t *testing.T) {
+	h := getHandlersMemory()
+	h.cfg.RedirectCacheMaxAge = time.Hour
+
+	ts := httptest.NewServer(newRouter(h))
+	defer ts.Close()
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Transport: &http.Transport{DisableCompression: true},
+	}
+
+	tests := []struct {
+		name         string
+		body         string
+		wantSetCode  int
+		wantCode     int
+		cacheControl string
+	}{
+		{
+			name:         "default",
+			body:         `{"url": "https://practicum.yandex.ru/default", "alias": "default-code"}`,
+			wantSetCode:  http.StatusCreated,
+			wantCode:     http.StatusTemporaryRedirect,
+			cacheControl: "no-store",
+		},
+		{
+			name:         "permanent",
+			body:         `{"url": "https://practicum.yandex.ru/seo", "alias": "seo", "redirect_code": 308}`,
+			wantSetCode:  http.StatusCreated,
+			wantCode:     http.StatusPermanentRedirect,
+			cacheControl: "public, max-age=3600",
+		},
+		{
+			name:         "permanent with clicks limit",
+			body:         `{"url": "https://practicum.yandex.ru/limited", "alias": "limited", "redirect_code": 301, "max_clicks": 10}`,
+			wantSetCode:  http.StatusCreated,
+			wantCode:     http.StatusMovedPermanently,
+			cacheControl: "no-store",
+		},
+		{
+			name:         "tracking",
+			body:         `{"url": "https://practicum.yandex.ru/tracking", "alias": "tracking", "redirect_code": 302}`,
+			wantSetCode:  http.StatusCreated,
+			wantCode:     http.StatusFound,
+			cacheControl: "no-store",
+		},
+		{
+			name:        "invalid",
+			body:        `{"url": "https://practicum.yandex.ru/invalid", "alias": "invalid", "redirect_code": 200}`,
+			wantSetCode: http.StatusBadRequest,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resSet, err := client.Post(ts.URL+"/api/shorten", "application/json", strings.NewReader(test.body))
+			require.NoError(t, err)
+			resSet.Body.Close()
+			require.Equal(t, test.wantSetCode, resSet.StatusCode)
+			if test.wantSetCode != http.StatusCreated {
+				return
+			}
+
+			var req models.Request
+			require.NoError(t, json.Unmarshal([]byte(test.body), &req))
+
+			resGet, err := client.Get(ts.URL + "/" + req.Alias)
+			require.NoError(t, err)
+			resGet.Body.Close()
+
+			assert.Equal(t, test.wantCode, resGet.StatusCode)
+			assert.Equal(t, req.URL, resGet.Header.Get("Location"))
+			assert.Equal(t, test.cacheControl, resGet.Header.Get("Cache-Control"))
+		})
+	}
+}
+
+func Test_handlers_GetShortenerPassthrough(t *testing.T) {
+	h := getHandlersMemory()
+
+	ts := httptest.NewServer(newRouter(h))
+	defer ts.Close()
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	for _, body := range []string{
+		`{"url": "https://practicum.yandex.ru/base?ref=partner", "alias": "docs", "passthrough": true}`,
+		`{"url": "https://practicum.yandex.ru/base", "alias": "plain"}`,
+	} {
+		resSet, err := client.Post(ts.URL+"/api/shorten", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		resSet.Body.Close()
+		require.Equal(t, http.StatusCreated, resSet.StatusCode)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		code     int
+		location string
+	}{
+		{
+			name:     "path and query",
+			path:     "/docs/guide/page?x=1",
+			code:     http.StatusTemporaryRedirect,
+			location: "https://practicum.yandex.ru/base/guide/page?ref=partner&x=1",
+		},
+		{
+			name:     "encoded path",
+			path:     "/docs/a%20b/c%2Fd",
+			code:     http.StatusTemporaryRedirect,
+			location: "https://practicum.yandex.ru/base/a%20b/c%2Fd?ref=partner",
+		},
+		{
+			name:     "link only",
+			path:     "/docs",
+			code:     http.StatusTemporaryRedirect,
+			location: "https://practicum.yandex.ru/base?ref=partner",
+		},
+		{
+			name: "encoded dot segment",
+			path: "/docs/%2e%2e/admin",
+			code: http.StatusBadRequest,
+		},
+		{
+			name:     "query without passthrough",
+			path:     "/plain?x=1",
+			code:     http.StatusTemporaryRedirect,
+			location: "https://practicum.yandex.ru/base",
+		},
+		{
+			name: "path without passthrough",
+			path: "/plain/guide",
+			code: http.StatusNotFound,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := client.Get(ts.URL + test.path)
+			require.NoError(t, err)
+			res.Body.Close()
+
+			assert.Equal(t, test.code, res.StatusCode)
+			assert.Equal(t, test.location, res.Header.Get("Location"))
+		})
+	}
+}
+
+func Test_handlers_GetShortenerTargets(t *testing.T) {
+	h := getHandlersMemory()
+
+	ts := httptest.NewServer(newRouter(h))
+	defer ts.Close()
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := &http.Client{
+		Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Transport: &http.Transport{DisableCompression: true},
+	}
+
+	resInvalid, err := client.Post(ts.URL+"/api/shorten", "application/json", strings.NewReader(
+		`{"url": "https://practicum.yandex.ru/", "alias": "split-invalid", "targets": [{"url": "https://a.example.com/", "weight": 0}]}`))
+	require.NoError(t, err)
+	resInvalid.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resInvalid.StatusCode)
+
+	resSet, err := client.Post(ts.URL+"/api/shorten", "application/json", strings.NewReader(
+		`{"url": "https://practicum.yandex.ru/", "alias": "split", "sticky": true, "redirect_code": 308,
+		  "targets": [{"url": "https://a.example.com/", "weight": 70}, {"url": "https://b.example.com/", "weight": 30}]}`))
+	require.NoError(t, err)
+	resSet.Body.Close()
+	require.Equal(t, http.StatusCreated, resSet.StatusCode)
+
+	res, err := client.Get(ts.URL + "/split")
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusPermanentRedirect, res.StatusCode)
+	assert.Equal(t, "no-store", res.Header.Get("Cache-Control"))
+
+	location := res.Header.Get("Location")
+	assert.Contains(t, []string{"https://a.example.com/", "https://b.example.com/"}, location)
+
+	// the cookie keeps the visitor with the destination picked for them
+	for i := 0; i < 10; i++ {
+		res, err = client.Get(ts.URL + "/split")
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, location, res.Header.Get("Location"))
+	}
+
+	requestPatch, err := http.NewRequest(http.MethodPatch, ts.URL+"/api/shorten/split", strings.NewReader(`{"url": "https://ya.ru"}`))
+	require.NoError(t, err)
+	requestPatch.Header.Add("Content-Type", "application/json")
+	resPatch, err := client.Do(requestPatch)
+	require.NoError(t, err)
+	defer resPatch.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, resPatch.StatusCode)
+	body, err := io.ReadAll(resPatch.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "splits traffic between targets")
+}
+
+func Test_handlers_GetShortenerRules(t *testing.T) {
+	h := getHandlersMemory()
+
+	ts := httptest.NewServer(newRouter(h))
+	defer ts.Close()
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resSet, err := client.Post(ts.URL+"/api/shorten", "application/json", strings.NewReader(
+		`{"url": "https://practicum.yandex.ru/", "alias": "app", "redirect_code": 301,
+		  "rules": [{"device": "mobile", "url": "https://apps.example.com/"}, {"language": "de", "url": "https://practicum.yandex.ru/de"}]}`))
+	require.NoError(t, err)
+	resSet.Body.Close()
+	require.Equal(t, http.StatusCreated, resSet.StatusCode)
+
+	tests := []struct {
+		name           string
+		userAgent      string
+		acceptLanguage string
+		location       string
+	}{
+		{
+			name:      "mobile",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile/15E148",
+			location:  "https://apps.example.com/",
+		},
+		{
+			name:           "german",
+			userAgent:      "Mozilla/5.0 (X11; Linux x86_64) Firefox/120.0",
+			acceptLanguage: "de-DE,de;q=0.9",
+			location:       "https://practicum.yandex.ru/de",
+		},
+		{
+			name:           "default",
+			userAgent:      "Mozilla/5.0 (X11; Linux x86_64) Firefox/120.0",
+			acceptLanguage: "en-US",
+			location:       "https://practicum.yandex.ru/",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/app", nil)
+			require.NoError(t, err)
+			req.Header.Set("User-Agent", test.userAgent)
+			req.Header.Set("Accept-Language", test.acceptLanguage)
+
+			res, err := client.Do(req)
+			require.NoError(t, err)
+			res.Body.Close()
+
+			assert.Equal(t, http.StatusMovedPermanently, res.StatusCode)
+			assert.Equal(t, test.location, res.Header.Get("Location"))
+			assert.Equal(t, "no-store", res.Header.Get("Cache-Control"))
+		})
+	}
+}
+
+func Test_handlers_GetShortenerPreview(t *testing.T) {
+	h := getHandlersMemory()
+
+	ts := httptest.NewServer(newRouter(h))
+	defer ts.Close()
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Transport: &http.Transport{DisableCompression: true},
+	}
+
+	for _, body := range []string{
+		`{"url": "https://practicum.yandex.ru/course", "alias": "course", "title": "Go course", "max_clicks": 1}`,
+		`{"url": "https://practicum.yandex.ru/old", "alias": "old", "ttl_seconds": 1}`,
+	} {
+		resSet, err := client.Post(ts.URL+"/api/shorten", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		resSet.Body.Close()
+		require.Equal(t, http.StatusCreated, resSet.StatusCode)
+	}
+
+	for _, path := range []string{"/course+", "/course?preview=1"} {
+		res, err := client.Get(ts.URL + path)
+		require.NoError(t, err)
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode, path)
+		assert.Equal(t, "no-store", res.Header.Get("Cache-Control"))
+		assert.Contains(t, string(body), `href="https://practicum.yandex.ru/course"`)
+		assert.Contains(t, string(body), "Go course")
+		assert.Contains(t, string(body), `src="data:image/png;base64,`)
+	}
+
+	// previews do not count as clicks
+	res, err := client.Get(ts.URL + "/course")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusTemporaryRedirect, res.StatusCode)
+
+	res, err = client.Get(ts.URL + "/course+")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusGone, res.StatusCode)
+
+	time.Sleep(1100 * time.Millisecond)
+	res, err = client.Get(ts.URL + "/old+")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusGone, res.StatusCode)
+
+	res, err = client.Get(ts.URL + "/missing+")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func Test_handlers_GetShortenerQRAPI(t *testing.T) {
+	h := getHandlersMemory()
+
+	ts := httptest.NewServer(newRouter(h))
+	defer ts.Close()
+
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+
+	resSet, err := client.Post(ts.URL+"/api/shorten", "application/json",
+		strings.NewReader(`{"url": "https://practicum.yandex.ru/poster", "alias": "poster"}`))
+	require.NoError(t, err)
+	resSet.Body.Close()
+	require.Equal(t, http.StatusCreated, resSet.StatusCode)
+
+	res, err := client.Get(ts.URL + "/api/shorten/poster/qr?size=300&margin=2&ec=H&fg=%23336699&bg=ffffff00")
+	require.NoError(t, err)
+	img, err := png.Decode(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "image/png", res.Header.Get("Content-Type"))
+	assert.Equal(t, image.Rect(0, 0, 300, 300), img.Bounds())
+
+	etag := res.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/shorten/poster/qr?size=300&margin=2&ec=H&fg=%23336699&bg=ffffff00", nil)
+	require.NoError(t, err)
+	req.Header.Set("If-None-Match", etag)
+	res, err = client.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotModified, res.StatusCode)
+
+	res, err = client.Get(ts.URL + "/api/shorten/poster/qr?format=svg")
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "image/svg+xml", res.Header.Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(string(body), "<svg "))
+	assert.NotEqual(t, etag, res.Header.Get("ETag"))
+
+	for _, query := range []string{"format=gif", "size=10", "margin=-1", "ec=X", "fg=blue", "size=32&margin=32"} {
+		res, err = client.Get(ts.URL + "/api/shorten/poster/qr?" + query)
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode, query)
+	}
+
+	res, err = client.Get(ts.URL + "/api/shorten/missing/qr")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func Test_handlers_GetShortenerScheduled(t *testing.T) {
+	h := getHandlersMemory()
+	h.cfg.RedirectCacheMaxAge = 24 * time.Hour
+
+	ts := httptest.NewServer(newRouter(h))
+	defer ts.Close()
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := &http.Client{
+		Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Transport: &http.Transport{DisableCompression: true},
+	}
+
+	now := time.Now().UTC()
+	for _, body := range []string{
+		`{"url": "https://practicum.yandex.ru/launch", "alias": "launch", "active_from": "` + now.Add(time.Hour).Format(time.RFC3339) + `"}`,
+		`{"url": "https://practicum.yandex.ru/teaser", "alias": "campaign", "redirect_code": 308, "versions": [
+			{"from": "` + now.Add(-time.Minute).Format(time.RFC3339) + `", "url": "https://practicum.yandex.ru/product"},
+			{"from": "` + now.Add(time.Hour).Format(time.RFC3339) + `", "url": "https://practicum.yandex.ru/sale"}]}`,
+	} {
+		resSet, err := client.Post(ts.URL+"/api/shorten", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		resSet.Body.Close()
+		require.Equal(t, http.StatusCreated, resSet.StatusCode)
+	}
+
+	res, err := client.Get(ts.URL + "/launch")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusTooEarly, res.StatusCode)
+
+	h.cfg.ScheduledNotFound = true
+	res, err = client.Get(ts.URL + "/launch")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	res, err = client.Get(ts.URL + "/campaign")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusPermanentRedirect, res.StatusCode)
+	assert.Equal(t, "https://practicum.yandex.ru/product", res.Header.Get("Location"))
+
+	// the redirect is only cached until the next version starts
+	var maxAge int
+	_, err = fmt.Sscanf(res.Header.Get("Cache-Control"), "public, max-age=%d", &maxAge)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, maxAge, 3600)
+	assert.Greater(t, maxAge, 3500)
+
+	requestPatch, err := http.NewRequest(http.MethodPatch, ts.URL+"/api/shorten/campaign", strings.NewReader(`{"url": "https://ya.ru"}`))
+	require.NoError(t, err)
+	requestPatch.Header.Add("Content-Type", "application/json")
+	resPatch, err := client.Do(requestPatch)
+	require.NoError(t, err)
+	defer resPatch.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, resPatch.StatusCode)
+	body, err := io.ReadAll(resPatch.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "switches targets on a schedule")
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/Evlushin/shorturl/internal/logger"
+	"github.com/Evlushin/shorturl/internal/models"
+	"go.uber.org/zap"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+const previewQRSize = 256
+
+var previewTemplate = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>{{if .Title}}{{.Title}}{{else}}Link preview{{end}}</title>
+</head>
+<body>
+<h1>{{if .Title}}{{.Title}}{{else}}Link preview{{end}}</h1>
+<p>{{.ShortURL}} leads to</p>
+<p><a href="{{.URL}}" rel="noopener noreferrer nofollow">{{.URL}}</a></p>
+{{if .CreatedAt}}<p>Created {{.CreatedAt}}</p>{{end}}
+{{if .QRCode}}<img src="{{.QRCode}}" width="{{.QRSize}}" height="{{.QRSize}}" alt="QR code of {{.ShortURL}}">{{end}}
+</body>
+</html>
+`))
+
+type previewPage struct {
+	ShortURL  string
+	URL       string
+	Title     string
+	CreatedAt string
+	QRCode    template.URL
+	QRSize    int
+}
+
+// renderPreview shows where the link leads instead of redirecting.
+func (h *handlers) renderPreview(w http.ResponseWriter, req *models.GetShortenerRequest, resp *models.GetShortenerResponse) {
+	page := previewPage{
+		ShortURL: h.shortURL(req.Domain, req.ID),
+		URL:      resp.URL,
+		Title:    resp.Title,
+		QRSize:   previewQRSize,
+	}
+	if !resp.CreatedAt.IsZero() {
+		page.CreatedAt = resp.CreatedAt.UTC().Format(time.RFC1123)
+	}
+
+	qr := new(bytes.Buffer)
+	opts := defaultQROptions()
+	opts.size = previewQRSize
+	if err := writeQR(qr, page.ShortURL, opts); err != nil {
+		logger.Log.Error("failed to encode QR code", zap.Error(err))
+	} else {
+		page.QRCode = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(qr.Bytes()))
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	renderHTML(w, previewTemplate, page, http.StatusOK)
+}
@@ -0,0 +1,289 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/Evlushin/shorturl/internal/logger"
+	"github.com/Evlushin/shorturl/internal/models"
+	"github.com/Evlushin/shorturl/internal/myerrors"
+	"github.com/go-chi/chi/v5"
+	"github.com/skip2/go-qrcode"
+	"go.uber.org/zap"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	qrFormatPNG = "png"
+	qrFormatSVG = "svg"
+
+	defaultQRSize   = 256
+	minQRSize       = 32
+	maxQRSize       = 2048
+	defaultQRMargin = 4
+	maxQRMargin     = 32
+
+	qrCacheMaxAge = 24 * 60 * 60
+)
+
+var qrLevels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// qrOptions describe the rendered QR code, size is in pixels and margin in modules.
+type qrOptions struct {
+	format     string
+	size       int
+	margin     int
+	level      string
+	foreground color.NRGBA
+	background color.NRGBA
+}
+
+func defaultQROptions() qrOptions {
+	return qrOptions{
+		format:     qrFormatPNG,
+		size:       defaultQRSize,
+		margin:     defaultQRMargin,
+		level:      "M",
+		foreground: color.NRGBA{A: 0xff},
+		background: color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+}
+
+func parseQROptions(r *http.Request) (qrOptions, error) {
+	opts := defaultQROptions()
+	query := r.URL.Query()
+
+	if format := strings.ToLower(query.Get("format")); format != "" {
+		if format != qrFormatPNG && format != qrFormatSVG {
+			return opts, fmt.Errorf("%w : format : %s", myerrors.ErrValidateShortenerInvalidRequest, format)
+		}
+		opts.format = format
+	}
+
+	if size := query.Get("size"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil || n < minQRSize || n > maxQRSize {
+			return opts, fmt.Errorf("%w : size : %s", myerrors.ErrValidateShortenerInvalidRequest, size)
+		}
+		opts.size = n
+	}
+
+	if margin := query.Get("margin"); margin != "" {
+		n, err := strconv.Atoi(margin)
+		if err != nil || n < 0 || n > maxQRMargin {
+			return opts, fmt.Errorf("%w : margin : %s", myerrors.ErrValidateShortenerInvalidRequest, margin)
+		}
+		opts.margin = n
+	}
+
+	if level := strings.ToUpper(query.Get("ec")); level != "" {
+		if _, ok := qrLevels[level]; !ok {
+			return opts, fmt.Errorf("%w : ec : %s", myerrors.ErrValidateShortenerInvalidRequest, level)
+		}
+		opts.level = level
+	}
+
+	for name, c := range map[string]*color.NRGBA{"fg": &opts.foreground, "bg": &opts.background} {
+		if value := query.Get(name); value != "" {
+			parsed, err := parseHexColor(value)
+			if err != nil {
+				return opts, fmt.Errorf("%w : %s : %s", myerrors.ErrValidateShortenerInvalidRequest, name, value)
+			}
+			*c = parsed
+		}
+	}
+
+	return opts, nil
+}
+
+// parseHexColor accepts RRGGBB and RRGGBBAA colours, the leading # is optional.
+func parseHexColor(s string) (color.NRGBA, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "#"))
+	if err != nil {
+		return color.NRGBA{}, err
+	}
+
+	switch len(b) {
+	case 3:
+		return color.NRGBA{R: b[0], G: b[1], B: b[2], A: 0xff}, nil
+	case 4:
+		return color.NRGBA{R: b[0], G: b[1], B: b[2], A: b[3]}, nil
+	}
+	return color.NRGBA{}, errors.New("invalid color length")
+}
+
+// etag identifies the rendered code, which only depends on its content and options.
+func (o qrOptions) etag(content string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d|%d|%s|%x|%x",
+		content, o.format, o.size, o.margin, o.level, o.foreground, o.background)))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+func (o qrOptions) contentType() string {
+	if o.format == qrFormatSVG {
+		return "image/svg+xml"
+	}
+	return "image/png"
+}
+
+// writeQR renders the code with the margin drawn here, so that it can be sized independently of the library border.
+func writeQR(w io.Writer, content string, opts qrOptions) error {
+	q, err := qrcode.New(content, qrLevels[opts.level])
+	if err != nil {
+		return err
+	}
+	q.DisableBorder = true
+	bitmap := q.Bitmap()
+
+	modules := len(bitmap) + 2*opts.margin
+	if opts.size < modules {
+		return fmt.Errorf("%w : size : %d is less than %d modules", myerrors.ErrValidateShortenerInvalidRequest, opts.size, modules)
+	}
+
+	if opts.format == qrFormatSVG {
+		return writeQRSVG(w, bitmap, opts)
+	}
+	return writeQRPNG(w, bitmap, opts)
+}
+
+func writeQRPNG(w io.Writer, bitmap [][]bool, opts qrOptions) error {
+	img := image.NewPaletted(image.Rect(0, 0, opts.size, opts.size), color.Palette{opts.background, opts.foreground})
+
+	// the modules are scaled to whole pixels and the rest of the image is spread around them
+	scale := opts.size / (len(bitmap) + 2*opts.margin)
+	offset := (opts.size - len(bitmap)*scale) / 2
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				start := img.PixOffset(offset+x*scale, offset+y*scale+dy)
+				for dx := 0; dx < scale; dx++ {
+					img.Pix[start+dx] = 1
+				}
+			}
+		}
+	}
+
+	return png.Encode(w, img)
+}
+
+func writeQRSVG(w io.Writer, bitmap [][]bool, opts qrOptions) error {
+	modules := len(bitmap) + 2*opts.margin
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.size, opts.size, modules, modules)
+	fmt.Fprintf(buf, `<rect width="%d" height="%d" %s/>`, modules, modules, svgFill(opts.background))
+	fmt.Fprintf(buf, `<path %s d="`, svgFill(opts.foreground))
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(buf, "M%d,%dh1v1h-1z", x+opts.margin, y+opts.margin)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func svgFill(c color.NRGBA) string {
+	fill := fmt.Sprintf(`fill="#%02x%02x%02x"`, c.R, c.G, c.B)
+	if c.A != 0xff {
+		fill += fmt.Sprintf(` fill-opacity="%.3f"`, float64(c.A)/0xff)
+	}
+	return fill
+}
+
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *handlers) GetShortenerQRAPI(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	domain, err := h.requestDomain(r, "")
+	if err != nil {
+		logger.Log.Debug("bad request", zap.Int("status", 400), zap.Error(err))
+		errorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opts, err := parseQROptions(r)
+	if err != nil {
+		logger.Log.Debug("bad request", zap.Int("status", 400), zap.Error(err))
+		errorJSON(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	_, err = h.shortener.GetShortenerInfo(ctx, &models.GetShortenerRequest{
+		ID:     id,
+		Domain: domain,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, myerrors.ErrGetShortenerInvalidRequest) || errors.Is(err, myerrors.ErrValidateShortenerInvalidRequest):
+			logger.Log.Debug("bad request", zap.Int("status", 400), zap.Error(err))
+			errorJSON(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, myerrors.ErrGetShortenerNotFound):
+			logger.Log.Debug("not found", zap.Int("status", 404), zap.Error(err))
+			errorJSON(w, err.Error(), http.StatusNotFound)
+		default:
+			logger.Log.Error("failed get shortener info", zap.Error(err))
+			errorJSON(w, myerrors.ErrInternalServer.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// the code only depends on the request, so a cached one is confirmed without rendering it
+	shortURL := h.shortURL(domain, id)
+	etag := opts.etag(shortURL)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", qrCacheMaxAge))
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	if err = writeQR(buf, shortURL, opts); err != nil {
+		if errors.Is(err, myerrors.ErrValidateShortenerInvalidRequest) {
+			logger.Log.Debug("bad request", zap.Int("status", 400), zap.Error(err))
+			errorJSON(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger.Log.Error("failed to encode QR code", zap.Error(err))
+		errorJSON(w, myerrors.ErrInternalServer.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", qrCacheMaxAge))
+	w.Header().Set("Content-Type", opts.contentType())
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/Evlushin/shorturl/internal/models"
+	"github.com/go-chi/chi/v5"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// redirectCacheControl lets clients cache permanent redirects, but only while nothing about
+// the link can change: links counting clicks, splitting or targeting visitors must reach us on every visit.
+func (h *handlers) redirectCacheControl(code int, resp *models.GetShortenerResponse) string {
+	if code != http.StatusMovedPermanently && code != http.StatusPermanentRedirect {
+		return "no-store"
+	}
+	if resp.ClicksLeft != nil || resp.Variant != "" || len(resp.Rules) > 0 {
+		return "no-store"
+	}
+
+	maxAge := h.cfg.RedirectCacheMaxAge
+	if resp.ExpiresAt != nil {
+		maxAge = min(maxAge, time.Until(*resp.ExpiresAt))
+	}
+	// the redirect changes with the next scheduled version
+	now := time.Now()
+	for _, version := range resp.Versions {
+		if version.From.After(now) {
+			maxAge = min(maxAge, version.From.Sub(now))
+			break
+		}
+	}
+	if maxAge <= 0 {
+		return "no-cache"
+	}
+
+	return fmt.Sprintf("public, max-age=%d", int64(maxAge/time.Second))
+}
+
+const (
+	variantCookie       = "variant"
+	variantCookieMaxAge = 90 * 24 * time.Hour
+)
+
+func requestVariant(r *http.Request) string {
+	c, err := r.Cookie(variantCookie)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+// setVariantCookie makes the visitor of a sticky link stay with the destination picked for them.
+func setVariantCookie(w http.ResponseWriter, id string, resp *models.GetShortenerResponse) {
+	if !resp.Sticky || resp.Variant == "" {
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     variantCookie,
+		Value:    resp.Variant,
+		Path:     "/" + id,
+		MaxAge:   int(variantCookieMaxAge / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// getShortenerRequest describes the visitor of a short link. A "+" after the ID or the preview=1 parameter
+// asks for the preview page instead of the redirect.
+func (h *handlers) getShortenerRequest(r *http.Request) *models.GetShortenerRequest {
+	req := &models.GetShortenerRequest{
+		ID:             chi.URLParam(r, "id"),
+		Domain:         h.hostDomain(r),
+		Path:           passthroughPath(r),
+		RawQuery:       r.URL.RawQuery,
+		Variant:        requestVariant(r),
+		UserAgent:      r.UserAgent(),
+		AcceptLanguage: r.Header.Get("Accept-Language"),
+		ClientIP:       clientIP(r),
+	}
+
+	if id, ok := strings.CutSuffix(req.ID, "+"); ok {
+		req.ID, req.Preview = id, true
+	}
+	if query := r.URL.Query(); query.Get("preview") == "1" {
+		query.Del("preview")
+		req.RawQuery, req.Preview = query.Encode(), true
+	}
+
+	return req
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"bytes"
+	"github.com/Evlushin/shorturl/internal/logger"
+	"go.uber.org/zap"
+	"html/template"
+	"net/http"
+	"strconv"
+)
+
+var passwordTemplate = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Password required</title>
+</head>
+<body>
+<form method="post">
+<p>This link is protected by a password.</p>
+{{if .Error}}<p>{{.Error}}</p>{{end}}
+<input type="password" name="password" autofocus>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+type passwordPage struct {
+	Error string
+}
+
+var defaultNotFoundTemplate = template.Must(template.New("not-found").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Status}} {{.StatusText}}</title>
+</head>
+<body>
+<h1>{{.StatusText}}</h1>
+<p>{{.Message}}</p>
+</body>
+</html>
+`))
+
+var blockedTemplate = template.Must(template.New("blocked").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Warning: link blocked</title>
+</head>
+<body>
+<h1>Warning</h1>
+<p>{{.Message}}</p>
+</body>
+</html>
+`))
+
+type errorPage struct {
+	Status     int
+	StatusText string
+	Message    string
+	ID         string
+}
+
+func loadNotFoundTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		return defaultNotFoundTemplate, nil
+	}
+
+	return template.ParseFiles(path)
+}
+
+func renderHTML(w http.ResponseWriter, tmpl *template.Template, data any, code int) {
+	buf := new(bytes.Buffer)
+	if err := tmpl.Execute(buf, data); err != nil {
+		logger.Log.Error("failed to render template", zap.String("template", tmpl.Name()), zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(code)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/Evlushin/shorturl/internal/logger"
+	"github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+const (
+	AuthCookieName = "token"
+	tokenExp       = time.Hour * 24 * 365
+)
+
+type ctxKey int
+
+const userIDKey ctxKey = iota
+
+var errInvalidToken = errors.New("invalid token")
+
+type claims struct {
+	jwt.RegisteredClaims
+	UserID string `json:"user_id"`
+}
+
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(userIDKey).(string)
+	return userID, ok && userID != ""
+}
+
+func newUserID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func buildToken(userID string, secretKey string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(tokenExp)),
+		},
+		UserID: userID,
+	})
+
+	return token.SignedString([]byte(secretKey))
+}
+
+func parseToken(tokenString string, secretKey string) (string, error) {
+	c := &claims{}
+	token, err := jwt.ParseWithClaims(tokenString, c, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return []byte(secretKey), nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if !token.Valid || c.UserID == "" {
+		return "", errInvalidToken
+	}
+
+	return c.UserID, nil
+}
+
+func userIDFromCookie(r *http.Request, secretKey string) (string, error) {
+	cookie, err := r.Cookie(AuthCookieName)
+	if err != nil {
+		return "", err
+	}
+
+	return parseToken(cookie.Value, secretKey)
+}
+
+// AuthMiddleware puts the user ID from the signed cookie into the request context.
+// A new user is issued when the cookie is missing or its signature is invalid.
+func AuthMiddleware(secretKey string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, err := userIDFromCookie(r, secretKey)
+			if err != nil {
+				userID, err = newUserID()
+				if err != nil {
+					logger.Log.Error("failed to generate user id", zap.Error(err))
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+
+				token, err := buildToken(userID, secretKey)
+				if err != nil {
+					logger.Log.Error("failed to build token", zap.Error(err))
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+
+				http.SetCookie(w, &http.Cookie{
+					Name:     AuthCookieName,
+					Value:    token,
+					Path:     "/",
+					Expires:  time.Now().Add(tokenExp),
+					HttpOnly: true,
+				})
+			}
+
+			ctx := context.WithValue(r.Context(), userIDKey, userID)
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireAuthMiddleware rejects requests that did not come with a valid signed cookie.
+func RequireAuthMiddleware(secretKey string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, err := userIDFromCookie(r, secretKey)
+			if err != nil {
+				logger.Log.Debug("unauthorized", zap.Int("status", 401), zap.Error(err))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userIDKey, userID)
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -1,12 +1,86 @@
 package models
 
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	StatusActive    = "active"
+	StatusDeleted   = "deleted"
+	StatusExpired   = "expired"
+	StatusExhausted = "exhausted"
+	StatusScheduled = "scheduled"
+)
+
+// IsRedirectCode reports whether links can redirect with the status code.
+func IsRedirectCode(code int) bool {
+	switch code {
+	case 301, 302, 303, 307, 308:
+		return true
+	}
+	return false
+}
+
+// DomainKey is the domain that scopes the IDs of links served from the base address.
+func DomainKey(baseURL string) string {
+	u, err := url.Parse(strings.TrimSpace(baseURL))
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// Target is one of the destinations a link splits its visitors between in proportion to the weights.
+type Target struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// Rule sends the visitors matching all of its set conditions to its URL instead of the link one.
+// Device is mobile, tablet or desktop, Language is a language tag like de or de-AT and Country is an ISO 3166 code.
+type Rule struct {
+	Device   string `json:"device,omitempty"`
+	Language string `json:"language,omitempty"`
+	Country  string `json:"country,omitempty"`
+	URL      string `json:"url"`
+}
+
+// Version replaces the link URL from its time on, until the next version.
+type Version struct {
+	From time.Time `json:"from"`
+	URL  string    `json:"url"`
+}
+
 type Request struct {
-	URL string `json:"url"`
+	URL          string     `json:"url"`
+	Domain       string     `json:"domain,omitempty"`
+	Alias        string     `json:"alias,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	TTLSeconds   *int64     `json:"ttl_seconds,omitempty"`
+	ActiveFrom   *time.Time `json:"active_from,omitempty"`
+	Versions     []Version  `json:"versions,omitempty"`
+	Password     string     `json:"password,omitempty"`
+	MaxClicks    *int64     `json:"max_clicks,omitempty"`
+	RedirectCode int        `json:"redirect_code,omitempty"`
+	Passthrough  bool       `json:"passthrough,omitempty"`
+	Targets      []Target   `json:"targets,omitempty"`
+	Sticky       bool       `json:"sticky,omitempty"`
+	Rules        []Rule     `json:"rules,omitempty"`
+	Title        string     `json:"title,omitempty"`
+	Description  string     `json:"description,omitempty"`
+	Tags         []string   `json:"tags,omitempty"`
 }
 
 type RequestBatch struct {
-	CorrelationID string `json:"correlation_id"`
-	OriginalURL   string `json:"original_url"`
+	CorrelationID string   `json:"correlation_id"`
+	OriginalURL   string   `json:"original_url"`
+	Domain        string   `json:"domain,omitempty"`
+	Alias         string   `json:"alias,omitempty"`
+	Title         string   `json:"title,omitempty"`
+	Description   string   `json:"description,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
 }
 
 type Response struct {
@@ -18,12 +92,57 @@ type ResponseBatch struct {
 	ShortURL      string `json:"short_url"`
 }
 
+type ResponseUserURL struct {
+	ShortURL    string `json:"short_url"`
+	OriginalURL string `json:"original_url"`
+}
+
+type ResponseInfo struct {
+	ShortURL     string     `json:"short_url"`
+	OriginalURL  string     `json:"original_url,omitempty"`
+	Title        string     `json:"title,omitempty"`
+	Description  string     `json:"description,omitempty"`
+	Tags         []string   `json:"tags"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+	Owner        string     `json:"owner,omitempty"`
+	RedirectCode int        `json:"redirect_code,omitempty"`
+	Passthrough  bool       `json:"passthrough,omitempty"`
+	Targets      []Target   `json:"targets,omitempty"`
+	Sticky       bool       `json:"sticky,omitempty"`
+	Rules        []Rule     `json:"rules,omitempty"`
+	ActiveFrom   *time.Time `json:"active_from,omitempty"`
+	Versions     []Version  `json:"versions,omitempty"`
+	Clicks       int64      `json:"clicks"`
+	Status       string     `json:"status"`
+}
+
 type ErrorJSONResponse struct {
-	Message string `json:"message"`
+	Message string          `json:"message"`
+	Errors  []ErrorURLEntry `json:"errors,omitempty"`
+}
+
+type ErrorURLEntry struct {
+	CorrelationID string `json:"correlation_id,omitempty"`
+	URL           string `json:"url"`
+	Reason        string `json:"reason"`
 }
 
+// Domain scopes the link IDs, the empty domain is the default one.
+// Path (escaped) and RawQuery are the rest of the short URL, passed through to the target by links allowing it.
+// Variant is the target the visitor got before, sticky links keep redirecting to it.
+// UserAgent, AcceptLanguage and ClientIP are matched against the link rules.
+// Preview resolves the link without counting a click.
 type GetShortenerRequest struct {
-	ID string
+	ID             string
+	Domain         string
+	Password       string
+	Path           string
+	RawQuery       string
+	Variant        string
+	UserAgent      string
+	AcceptLanguage string
+	ClientIP       string
+	Preview        bool
 }
 
 type SetShortenerResponse struct {
@@ -36,16 +155,99 @@ type SetShortenerBatchResponse struct {
 }
 
 type GetShortenerResponse struct {
-	URL string
+	URL          string
+	UserID       string
+	IsDeleted    bool
+	ExpiresAt    *time.Time
+	PasswordHash string
+	ClicksLeft   *int64
+	RedirectCode int
+	Passthrough  bool
+	Targets      []Target
+	Sticky       bool
+	Rules        []Rule
+	ActiveFrom   *time.Time
+	Versions     []Version
+	Variant      string
+	Title        string
+	Description  string
+	Tags         []string
+	CreatedAt    time.Time
+	Clicks       int64
+	Status       string
 }
 
 type SetShortenerRequest struct {
-	ID  string
-	URL string
+	ID           string
+	Domain       string
+	URL          string
+	CanonicalURL string
+	UserID       string
+	Alias        string
+	ExpiresAt    *time.Time
+	TTLSeconds   *int64
+	Password     string
+	PasswordHash string
+	MaxClicks    *int64
+	RedirectCode int
+	Passthrough  bool
+	Targets      []Target
+	Sticky       bool
+	Rules        []Rule
+	ActiveFrom   *time.Time
+	Versions     []Version
+	Title        string
+	Description  string
+	Tags         []string
+	// Exclusive links have options of their own, so they are never shared with other requests for the URL
+	Exclusive bool
 }
 
 type SetShortenerBatchRequest struct {
 	CorrelationID string
 	ID            string
+	Domain        string
 	URL           string
+	CanonicalURL  string
+	UserID        string
+	Title         string
+	Description   string
+	Tags          []string
+	// Exclusive links are created under an alias, so they are never shared with other requests for the URL
+	Exclusive  bool
+	IDConflict bool
+}
+
+type GetUserShortenersRequest struct {
+	UserID string
+}
+
+type DeleteShortenerRequest struct {
+	ID     string
+	Domain string
+	UserID string
+}
+
+type UpdateShortenerRequest struct {
+	ID           string
+	Domain       string
+	URL          string
+	CanonicalURL string
+	UserID       string
+	ExistingID   string
+}
+
+type ConsumeShortenerClickRequest struct {
+	ID     string
+	Domain string
+}
+
+type DeleteExpiredShortenersRequest struct {
+	ExpiredBefore time.Time
+}
+
+type UserShortener struct {
+	ID     string
+	Domain string
+	URL    string
 }
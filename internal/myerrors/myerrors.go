@@ -1,6 +1,10 @@
 package myerrors
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 var (
 	ErrGetShortenerNotFound            = errors.New("no url")
@@ -12,4 +16,41 @@ var (
 	ErrJSONDecode                      = errors.New("error JSON decode")
 	ErrInternalServer                  = errors.New("internal Server Error")
 	ErrConflictURL                     = errors.New("URL conflict")
+	ErrConflictID                      = errors.New("ID conflict")
+	ErrUnauthorized                    = errors.New("unauthorized")
+	ErrGetShortenerDeleted             = errors.New("url deleted")
+	ErrConflictAlias                   = errors.New("alias conflict")
+	ErrGetShortenerExpired             = errors.New("url expired")
+	ErrPasswordRequired                = errors.New("password required")
+	ErrInvalidPassword                 = errors.New("invalid password")
+	ErrTooManyPasswordAttempts         = errors.New("too many password attempts")
+	ErrGetShortenerExhausted           = errors.New("url clicks exhausted")
+	ErrForbidden                       = errors.New("forbidden")
+	ErrGetShortenerBlocked             = errors.New("url blocked")
+	ErrGetShortenerNotActive           = errors.New("url not active yet")
 )
+
+// URLError tells why a target URL was rejected.
+type URLError struct {
+	CorrelationID string
+	URL           string
+	Reason        string
+}
+
+// URLPolicyError lists the rejected target URLs of a request.
+type URLPolicyError struct {
+	URLs []URLError
+}
+
+func (e *URLPolicyError) Error() string {
+	urls := make([]string, 0, len(e.URLs))
+	for _, u := range e.URLs {
+		urls = append(urls, fmt.Sprintf("%s (%s)", u.URL, u.Reason))
+	}
+
+	return fmt.Sprintf("%s : URLs : %s", ErrValidateShortenerInvalidRequest, strings.Join(urls, ", "))
+}
+
+func (e *URLPolicyError) Unwrap() error {
+	return ErrValidateShortenerInvalidRequest
+}
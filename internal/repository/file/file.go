@@ -3,79 +3,141 @@ package file
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"github.com/Evlushin/shorturl/internal/config"
+	"github.com/Evlushin/shorturl/internal/logger"
 	"github.com/Evlushin/shorturl/internal/models"
-	"github.com/Evlushin/shorturl/internal/myerrors"
 	"github.com/Evlushin/shorturl/internal/repository"
+	"github.com/Evlushin/shorturl/internal/repository/inmemory"
+	"go.uber.org/zap"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
+const clicksFlushInterval = 10 * time.Second
+
 type URLRecord struct {
-	UUID        string `json:"uuid"`
-	ShortURL    string `json:"short_url"`
-	OriginalURL string `json:"original_url"`
+	UUID     string `json:"uuid"`
+	ShortURL string `json:"short_url"`
+	Domain   string `json:"domain,omitempty"`
+	inmemory.Record
 }
 
+// Store keeps the links in memory and writes all of them to the file after every change,
+// except for plain click counts, which are written periodically and on Close.
+// The ID sequence is kept next to it, in the file with the .seq suffix.
 type Store struct {
-	mux *sync.RWMutex
-	s   map[string]string
-	cfg *config.Config
+	*inmemory.Store
+	saveMux  *sync.Mutex
+	saved    uint64
+	sequence uint64
+	cfg      *config.Config
+	done     chan struct{}
+	wg       *sync.WaitGroup
 }
 
 func NewStore(cfg *config.Config) (repository.Repository, error) {
-	store := &Store{
-		mux: &sync.RWMutex{},
-		s:   make(map[string]string),
-		cfg: cfg,
+	records, err := load(cfg.FileStorePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
 	}
 
-	if err := store.load(); err != nil {
-		if !os.IsNotExist(err) {
-			return nil, err
-		}
+	sequence, err := loadSequence(cfg.FileStorePath + ".seq")
+	if os.IsNotExist(err) {
+		// the counter used to restart on every start, so it continues from the number of links rather than from zero
+		sequence, err = uint64(len(records)), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	store := &Store{
+		Store:    inmemory.NewStoreFromRecords(cfg, records),
+		saveMux:  &sync.Mutex{},
+		sequence: sequence,
+		cfg:      cfg,
+		done:     make(chan struct{}),
+		wg:       &sync.WaitGroup{},
 	}
 
+	store.wg.Add(1)
+	go store.flushClicks(clicksFlushInterval)
+
 	return store, nil
 }
 
-func (st *Store) load() error {
-	st.mux.Lock()
-	defer st.mux.Unlock()
+// flushClicks saves the click counts left unsaved by ConsumeShortenerClick.
+func (st *Store) flushClicks(interval time.Duration) {
+	defer st.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := st.save(); err != nil {
+				logger.Log.Error("failed to save clicks", zap.Error(err))
+			}
+		case <-st.done:
+			return
+		}
+	}
+}
 
-	data, err := os.ReadFile(st.cfg.FileStorePath)
+func loadSequence(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+func load(path string) (map[inmemory.LinkKey]*inmemory.Record, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
 	}
 
 	var arr []URLRecord
 	if err := json.Unmarshal(data, &arr); err != nil {
-		return err
+		return nil, err
 	}
 
-	st.s = make(map[string]string)
-	for _, rec := range arr {
-		st.s[rec.ShortURL] = rec.OriginalURL
+	records := make(map[inmemory.LinkKey]*inmemory.Record, len(arr))
+	for i := range arr {
+		// records written before canonicalization are compared by the original URL
+		if arr[i].CanonicalURL == "" {
+			arr[i].CanonicalURL = arr[i].URL
+		}
+		records[inmemory.LinkKey{Domain: arr[i].Domain, ID: arr[i].ShortURL}] = &arr[i].Record
 	}
 
-	return nil
+	return records, nil
 }
 
+// save writes the links unless they have not changed since the last save.
 func (st *Store) save() error {
-	st.mux.Lock()
-	defer st.mux.Unlock()
+	st.saveMux.Lock()
+	defer st.saveMux.Unlock()
 
-	arr := make([]URLRecord, 0, len(st.s))
+	records, version := st.Store.Snapshot()
+	if version == st.saved {
+		return nil
+	}
+
+	arr := make([]URLRecord, 0, len(records))
 	id := 1
-	for shortURL, originalURL := range st.s {
-		rec := URLRecord{
-			UUID:        strconv.Itoa(id),
-			ShortURL:    shortURL,
-			OriginalURL: originalURL,
-		}
-		arr = append(arr, rec)
+	for key, rec := range records {
+		arr = append(arr, URLRecord{
+			UUID:     strconv.Itoa(id),
+			ShortURL: key.ID,
+			Domain:   key.Domain,
+			Record:   rec,
+		})
 		id++
 	}
 
@@ -83,74 +145,91 @@ func (st *Store) save() error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(st.cfg.FileStorePath, data, 0644)
-}
+	if err = os.WriteFile(st.cfg.FileStorePath, data, 0644); err != nil {
+		return err
+	}
+	st.saved = version
 
-func newErrGetShortenerNotFound(id string) error {
-	return fmt.Errorf("%w for id = %s", myerrors.ErrGetShortenerNotFound, id)
+	return nil
 }
 
-func (st *Store) GetShortener(ctx context.Context, req *models.GetShortenerRequest) (*models.GetShortenerResponse, error) {
-	st.mux.Lock()
-	defer st.mux.Unlock()
+// NextSequence saves the counter before handing the value out, so that it is never handed out again.
+func (st *Store) NextSequence(ctx context.Context) (uint64, error) {
+	st.saveMux.Lock()
+	defer st.saveMux.Unlock()
 
-	res, ok := st.s[req.ID]
-	if !ok {
-		return nil, newErrGetShortenerNotFound(req.ID)
+	n := st.sequence
+	if err := os.WriteFile(st.cfg.FileStorePath+".seq", []byte(strconv.FormatUint(n+1, 10)), 0644); err != nil {
+		return 0, err
 	}
-	return &models.GetShortenerResponse{
-		URL: res,
-	}, nil
+	st.sequence++
+
+	return n, nil
 }
 
 func (st *Store) SetShortener(ctx context.Context, req *models.SetShortenerRequest) error {
-	st.mux.Lock()
-	var errUniqueURL error
-	for key, v := range st.s {
-		if v == req.URL {
-			req.ID = key
-			errUniqueURL = myerrors.ErrConflictURL
-		}
+	if err := st.Store.SetShortener(ctx, req); err != nil {
+		return err
 	}
 
-	st.s[req.ID] = req.URL
-	st.mux.Unlock()
+	return st.save()
+}
+
+func (st *Store) SetShortenerBatch(ctx context.Context, req []models.SetShortenerBatchRequest) error {
+	errStore := st.Store.SetShortenerBatch(ctx, req)
 
-	err := st.save()
-	if err != nil {
+	if err := st.save(); err != nil {
 		return err
 	}
 
-	return errUniqueURL
+	return errStore
 }
 
-func (st *Store) SetShortenerBatch(ctx context.Context, req []models.SetShortenerBatchRequest) error {
-	st.mux.Lock()
-	var errUniqueURL error
-	for i, r := range req {
-		for key, v := range st.s {
-			if v == r.URL {
-				req[i].ID = key
-				r.ID = key
-				errUniqueURL = myerrors.ErrConflictURL
-			}
-		}
-		st.s[r.ID] = r.URL
+func (st *Store) UpdateShortener(ctx context.Context, req *models.UpdateShortenerRequest) error {
+	if err := st.Store.UpdateShortener(ctx, req); err != nil {
+		return err
+	}
+
+	return st.save()
+}
+
+func (st *Store) ConsumeShortenerClick(ctx context.Context, req *models.ConsumeShortenerClickRequest) error {
+	if err := st.Store.ConsumeShortenerClick(ctx, req); err != nil {
+		return err
 	}
-	st.mux.Unlock()
 
-	err := st.save()
+	// a used click of a limited link must survive a crash, plain counts can wait for the flush
+	resp, err := st.Store.GetShortener(ctx, &models.GetShortenerRequest{ID: req.ID, Domain: req.Domain})
 	if err != nil {
 		return err
 	}
+	if resp.ClicksLeft == nil {
+		return nil
+	}
 
-	return errUniqueURL
+	return st.save()
 }
 
-func (st *Store) Close() error {
-	return nil
+func (st *Store) DeleteShortenerBatch(ctx context.Context, req []models.DeleteShortenerRequest) error {
+	if err := st.Store.DeleteShortenerBatch(ctx, req); err != nil {
+		return err
+	}
+
+	return st.save()
 }
 
-func (st *Store) Ping(ctx context.Context) error {
-	return nil
+func (st *Store) DeleteExpiredShorteners(ctx context.Context, req *models.DeleteExpiredShortenersRequest) error {
+	if err := st.Store.DeleteExpiredShorteners(ctx, req); err != nil {
+		return err
+	}
+
+	return st.save()
+}
+
+// Close saves the click counts that are not saved yet.
+func (st *Store) Close() error {
+	close(st.done)
+	st.wg.Wait()
+
+	return st.save()
 }
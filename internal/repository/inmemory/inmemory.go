@@ -7,21 +7,85 @@ import (
 	"github.com/Evlushin/shorturl/internal/models"
 	"github.com/Evlushin/shorturl/internal/myerrors"
 	"github.com/Evlushin/shorturl/internal/repository"
+	"sort"
 	"sync"
+	"time"
 )
 
+// Record is a stored link. The file store persists records as they are, hence the JSON tags.
+// Pointer fields are replaced rather than changed in place, so copies stay valid without the lock.
+type Record struct {
+	URL          string           `json:"original_url"`
+	CanonicalURL string           `json:"canonical_url,omitempty"`
+	UserID       string           `json:"user_id,omitempty"`
+	IsDeleted    bool             `json:"is_deleted,omitempty"`
+	ExpiresAt    *time.Time       `json:"expires_at,omitempty"`
+	PasswordHash string           `json:"password_hash,omitempty"`
+	ClicksLeft   *int64           `json:"clicks_left,omitempty"`
+	RedirectCode int              `json:"redirect_code,omitempty"`
+	Passthrough  bool             `json:"passthrough,omitempty"`
+	Targets      []models.Target  `json:"targets,omitempty"`
+	Sticky       bool             `json:"sticky,omitempty"`
+	Rules        []models.Rule    `json:"rules,omitempty"`
+	ActiveFrom   *time.Time       `json:"active_from,omitempty"`
+	Versions     []models.Version `json:"versions,omitempty"`
+	Title        string           `json:"title,omitempty"`
+	Description  string           `json:"description,omitempty"`
+	Tags         []string         `json:"tags,omitempty"`
+	CreatedAt    time.Time        `json:"created_at"`
+	Clicks       int64            `json:"clicks,omitempty"`
+	Exclusive    bool             `json:"exclusive,omitempty"`
+}
+
+// LinkKey identifies a link, IDs are unique within a domain only.
+type LinkKey struct {
+	Domain string
+	ID     string
+}
+
+// urlKey identifies a target, URLs are deduplicated within a domain.
+type urlKey struct {
+	Domain string
+	URL    string
+}
+
 type Store struct {
 	mux *sync.RWMutex
-	s   map[string]string
+	s   map[LinkKey]*Record
 	cfg *config.Config
+	// version is bumped on every change, so that the stored links can be persisted only when changed
+	version  uint64
+	sequence uint64
 }
 
 func NewStore(cfg *config.Config) (repository.Repository, error) {
+	return NewStoreFromRecords(cfg, nil), nil
+}
+
+// NewStoreFromRecords makes a store holding the records, for stores persisting the in-memory links elsewhere.
+func NewStoreFromRecords(cfg *config.Config, records map[LinkKey]*Record) *Store {
+	if records == nil {
+		records = make(map[LinkKey]*Record)
+	}
+
 	return &Store{
 		mux: &sync.RWMutex{},
-		s:   make(map[string]string),
+		s:   records,
 		cfg: cfg,
-	}, nil
+	}
+}
+
+// Snapshot returns a copy of the stored links with the version they were taken at.
+func (s *Store) Snapshot() (map[LinkKey]Record, uint64) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	res := make(map[LinkKey]Record, len(s.s))
+	for key, v := range s.s {
+		res[key] = *v
+	}
+
+	return res, s.version
 }
 
 func newErrGetShortenerNotFound(id string) error {
@@ -32,12 +96,29 @@ func (s *Store) GetShortener(ctx context.Context, req *models.GetShortenerReques
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
-	res, ok := s.s[req.ID]
+	res, ok := s.s[LinkKey{Domain: req.Domain, ID: req.ID}]
 	if !ok {
 		return nil, newErrGetShortenerNotFound(req.ID)
 	}
 	return &models.GetShortenerResponse{
-		URL: res,
+		URL:          res.URL,
+		UserID:       res.UserID,
+		IsDeleted:    res.IsDeleted,
+		ExpiresAt:    res.ExpiresAt,
+		PasswordHash: res.PasswordHash,
+		ClicksLeft:   copyInt64(res.ClicksLeft),
+		RedirectCode: res.RedirectCode,
+		Passthrough:  res.Passthrough,
+		Targets:      res.Targets,
+		Sticky:       res.Sticky,
+		Rules:        res.Rules,
+		ActiveFrom:   res.ActiveFrom,
+		Versions:     res.Versions,
+		Title:        res.Title,
+		Description:  res.Description,
+		Tags:         res.Tags,
+		CreatedAt:    res.CreatedAt,
+		Clicks:       res.Clicks,
 	}, nil
 }
 
@@ -45,37 +126,234 @@ func (s *Store) SetShortener(ctx context.Context, req *models.SetShortenerReques
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
-	var errUniqueURL error
+	if id, ok := s.findURL(req.Domain, req.CanonicalURL); ok && !req.Exclusive {
+		req.ID = id
+		return myerrors.ErrConflictURL
+	}
+
+	key := LinkKey{Domain: req.Domain, ID: req.ID}
+	if _, ok := s.s[key]; ok {
+		return fmt.Errorf("%w for id = %s", myerrors.ErrConflictID, req.ID)
+	}
+
+	s.s[key] = &Record{
+		URL:          req.URL,
+		CanonicalURL: req.CanonicalURL,
+		UserID:       req.UserID,
+		ExpiresAt:    req.ExpiresAt,
+		PasswordHash: req.PasswordHash,
+		ClicksLeft:   copyInt64(req.MaxClicks),
+		RedirectCode: req.RedirectCode,
+		Passthrough:  req.Passthrough,
+		Targets:      req.Targets,
+		Sticky:       req.Sticky,
+		Rules:        req.Rules,
+		ActiveFrom:   req.ActiveFrom,
+		Versions:     req.Versions,
+		Title:        req.Title,
+		Description:  req.Description,
+		Tags:         req.Tags,
+		CreatedAt:    time.Now(),
+		Exclusive:    req.Exclusive,
+	}
+	s.version++
+
+	return nil
+}
+
+// findURL looks for a live link to the URL, deleted, expired, exhausted and exclusive links are not reused.
+func (s *Store) findURL(domain string, url string) (string, bool) {
+	now := time.Now()
 	for key, v := range s.s {
-		if v == req.URL {
-			req.ID = key
-			errUniqueURL = myerrors.ErrConflictURL
+		if key.Domain == domain && v.CanonicalURL == url && v.isLive(now) {
+			return key.ID, true
 		}
 	}
+	return "", false
+}
 
-	s.s[req.ID] = req.URL
+func (r *Record) isLive(now time.Time) bool {
+	switch {
+	case r.IsDeleted || r.Exclusive:
+		return false
+	case r.ExpiresAt != nil && !now.Before(*r.ExpiresAt):
+		return false
+	case r.ClicksLeft != nil && *r.ClicksLeft <= 0:
+		return false
+	}
+	return true
+}
 
-	return errUniqueURL
+// markBatchIDConflicts flags the new items whose IDs are already taken, so that nothing is stored.
+func (s *Store) markBatchIDConflicts(req []models.SetShortenerBatchRequest) error {
+	var errUniqueID error
+	urls := make(map[urlKey]struct{}, len(req))
+	ids := make(map[LinkKey]struct{}, len(req))
+	for i, r := range req {
+		req[i].IDConflict = false
+		if !r.Exclusive {
+			u := urlKey{Domain: r.Domain, URL: r.CanonicalURL}
+			if _, ok := urls[u]; ok {
+				continue
+			}
+			urls[u] = struct{}{}
+
+			if _, ok := s.findURL(r.Domain, r.CanonicalURL); ok {
+				continue
+			}
+		}
+
+		key := LinkKey{Domain: r.Domain, ID: r.ID}
+		_, taken := s.s[key]
+		_, repeated := ids[key]
+		if taken || repeated {
+			req[i].IDConflict = true
+			errUniqueID = myerrors.ErrConflictID
+			continue
+		}
+		ids[key] = struct{}{}
+	}
+
+	return errUniqueID
 }
 
 func (s *Store) SetShortenerBatch(ctx context.Context, req []models.SetShortenerBatchRequest) error {
 	s.mux.Lock()
 	defer s.mux.Unlock()
+
+	if err := s.markBatchIDConflicts(req); err != nil {
+		return err
+	}
+
 	var errUniqueURL error
 	for i, r := range req {
-		for key, v := range s.s {
-			if v == r.URL {
-				req[i].ID = key
-				r.ID = key
-				errUniqueURL = myerrors.ErrConflictURL
-			}
+		if id, ok := s.findURL(r.Domain, r.CanonicalURL); ok && !r.Exclusive {
+			req[i].ID = id
+			errUniqueURL = myerrors.ErrConflictURL
+			continue
+		}
+		s.s[LinkKey{Domain: r.Domain, ID: r.ID}] = &Record{
+			URL:          r.URL,
+			CanonicalURL: r.CanonicalURL,
+			UserID:       r.UserID,
+			Title:        r.Title,
+			Description:  r.Description,
+			Tags:         r.Tags,
+			CreatedAt:    time.Now(),
+			Exclusive:    r.Exclusive,
 		}
-		s.s[r.ID] = r.URL
+		s.version++
 	}
 
 	return errUniqueURL
 }
 
+func (s *Store) UpdateShortener(ctx context.Context, req *models.UpdateShortenerRequest) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	res, ok := s.s[LinkKey{Domain: req.Domain, ID: req.ID}]
+	if !ok || res.UserID != req.UserID || res.IsDeleted {
+		return newErrGetShortenerNotFound(req.ID)
+	}
+
+	if id, ok := s.findURL(req.Domain, req.CanonicalURL); ok && id != req.ID && !res.Exclusive {
+		req.ExistingID = id
+		return myerrors.ErrConflictURL
+	}
+
+	res.URL = req.URL
+	res.CanonicalURL = req.CanonicalURL
+	s.version++
+
+	return nil
+}
+
+func (s *Store) ConsumeShortenerClick(ctx context.Context, req *models.ConsumeShortenerClickRequest) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	res, ok := s.s[LinkKey{Domain: req.Domain, ID: req.ID}]
+	if !ok {
+		return newErrGetShortenerNotFound(req.ID)
+	}
+
+	if res.ClicksLeft != nil {
+		if *res.ClicksLeft <= 0 {
+			return fmt.Errorf("%w for id = %s", myerrors.ErrGetShortenerExhausted, req.ID)
+		}
+		clicksLeft := *res.ClicksLeft - 1
+		res.ClicksLeft = &clicksLeft
+	}
+	res.Clicks++
+	s.version++
+
+	return nil
+}
+
+func (s *Store) GetUserShorteners(ctx context.Context, req *models.GetUserShortenersRequest) ([]models.UserShortener, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	res := make([]models.UserShortener, 0)
+	for key, v := range s.s {
+		if v.UserID == req.UserID && !v.IsDeleted {
+			res = append(res, models.UserShortener{
+				ID:     key.ID,
+				Domain: key.Domain,
+				URL:    v.URL,
+			})
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Domain != res[j].Domain {
+			return res[i].Domain < res[j].Domain
+		}
+		return res[i].ID < res[j].ID
+	})
+
+	return res, nil
+}
+
+func (s *Store) DeleteShortenerBatch(ctx context.Context, req []models.DeleteShortenerRequest) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for _, r := range req {
+		if v, ok := s.s[LinkKey{Domain: r.Domain, ID: r.ID}]; ok && v.UserID == r.UserID && !v.IsDeleted {
+			v.IsDeleted = true
+			s.version++
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteExpiredShorteners(ctx context.Context, req *models.DeleteExpiredShortenersRequest) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for key, v := range s.s {
+		if v.ExpiresAt != nil && v.ExpiresAt.Before(req.ExpiredBefore) {
+			delete(s.s, key)
+			s.version++
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) NextSequence(ctx context.Context) (uint64, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	n := s.sequence
+	s.sequence++
+
+	return n, nil
+}
+
 func (s *Store) Close() error {
 	return nil
 }
@@ -83,3 +361,11 @@ func (s *Store) Close() error {
 func (s *Store) Ping(ctx context.Context) error {
 	return nil
 }
+
+func copyInt64(v *int64) *int64 {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
@@ -3,6 +3,7 @@ package pg
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/Evlushin/shorturl/internal/config"
@@ -12,10 +13,16 @@ import (
 	"github.com/Evlushin/shorturl/internal/repository/pg/migrator"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"time"
 )
 
+const primaryKeyConstraint = "shorteners_pkey"
+
+// liveCondition matches the links deduplicated by unique_canonical_url_idx.
+const liveCondition = `NOT is_deleted AND NOT exclusive AND (clicks_left IS NULL OR clicks_left > 0)`
+
 type URLRecord struct {
 	UUID        string `json:"uuid"`
 	ShortURL    string `json:"short_url"`
@@ -23,8 +30,9 @@ type URLRecord struct {
 }
 
 type Store struct {
-	cfg  *config.Config
-	conn *sql.DB
+	cfg     *config.Config
+	conn    *sql.DB
+	typeMap *pgtype.Map
 }
 
 func NewStore(cfg *config.Config) (repository.Repository, error) {
@@ -34,8 +42,9 @@ func NewStore(cfg *config.Config) (repository.Repository, error) {
 	}
 
 	store := &Store{
-		cfg:  cfg,
-		conn: conn,
+		cfg:     cfg,
+		conn:    conn,
+		typeMap: pgtype.NewMap(),
 	}
 
 	err = migrator.ApplyMigrations(conn, "file://./migrations")
@@ -51,8 +60,31 @@ func newErrGetShortenerNotFound(id string) error {
 }
 
 func (st *Store) GetShortener(ctx context.Context, req *models.GetShortenerRequest) (*models.GetShortenerResponse, error) {
-	var res models.GetShortenerResponse
-	err := st.conn.QueryRowContext(ctx, `SELECT URL FROM shorteners WHERE ID = $1 LIMIT 1`, req.ID).Scan(&res.URL)
+	var (
+		res          models.GetShortenerResponse
+		expiresAt    sql.NullTime
+		passwordHash sql.NullString
+		clicksLeft   sql.NullInt64
+		redirectCode sql.NullInt64
+		targets      []byte
+		rules        []byte
+		activeFrom   sql.NullTime
+		versions     []byte
+		userID       sql.NullString
+		title        sql.NullString
+		description  sql.NullString
+		createdAt    sql.NullTime
+	)
+	err := st.conn.QueryRowContext(ctx, `
+		SELECT URL, user_id, is_deleted, expires_at, password_hash, clicks_left, redirect_code, passthrough,
+		       targets, sticky, rules, active_from, versions, title, description, tags, created_at, clicks
+		FROM shorteners WHERE domain = $1 AND ID = $2 LIMIT 1
+	`, req.Domain, req.ID).Scan(
+		&res.URL, &userID, &res.IsDeleted, &expiresAt, &passwordHash, &clicksLeft, &redirectCode, &res.Passthrough,
+		&targets, &res.Sticky, &rules, &activeFrom, &versions,
+		&title, &description, st.typeMap.SQLScanner(&res.Tags),
+		&createdAt, &res.Clicks,
+	)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -61,53 +93,105 @@ func (st *Store) GetShortener(ctx context.Context, req *models.GetShortenerReque
 		return nil, err
 	}
 
+	if expiresAt.Valid {
+		res.ExpiresAt = &expiresAt.Time
+	}
+	res.UserID = userID.String
+	res.PasswordHash = passwordHash.String
+	res.Title = title.String
+	res.Description = description.String
+	res.CreatedAt = createdAt.Time
+	res.RedirectCode = int(redirectCode.Int64)
+	if targets != nil {
+		if err = json.Unmarshal(targets, &res.Targets); err != nil {
+			return nil, fmt.Errorf("failed to decode targets of id = %s: %w", req.ID, err)
+		}
+	}
+	if rules != nil {
+		if err = json.Unmarshal(rules, &res.Rules); err != nil {
+			return nil, fmt.Errorf("failed to decode rules of id = %s: %w", req.ID, err)
+		}
+	}
+	if activeFrom.Valid {
+		res.ActiveFrom = &activeFrom.Time
+	}
+	if versions != nil {
+		if err = json.Unmarshal(versions, &res.Versions); err != nil {
+			return nil, fmt.Errorf("failed to decode versions of id = %s: %w", req.ID, err)
+		}
+	}
+	if clicksLeft.Valid {
+		res.ClicksLeft = &clicksLeft.Int64
+	}
+
 	return &res, nil
 }
 
 func (st *Store) SetShortener(ctx context.Context, req *models.SetShortenerRequest) error {
 	var returnedID string
+	var targets, rules, versions []byte
+	if len(req.Targets) > 0 {
+		var err error
+		if targets, err = json.Marshal(req.Targets); err != nil {
+			return err
+		}
+	}
+	if len(req.Rules) > 0 {
+		var err error
+		if rules, err = json.Marshal(req.Rules); err != nil {
+			return err
+		}
+	}
+	if len(req.Versions) > 0 {
+		var err error
+		if versions, err = json.Marshal(req.Versions); err != nil {
+			return err
+		}
+	}
+
 	_, err := st.conn.ExecContext(ctx, `
         INSERT INTO shorteners
-        (ID, URL, created_at)
+        (ID, domain, URL, canonical_url, user_id, expires_at, password_hash, clicks_left, redirect_code, passthrough,
+         targets, sticky, rules, active_from, versions, title, description, tags, created_at, exclusive)
         VALUES
-        ($1, $2, $3);
-    `, req.ID, req.URL, time.Now())
+        ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, NULLIF($9, 0), $10, $11, $12, $13, $14, $15, NULLIF($16, ''),
+         NULLIF($17, ''), $18, $19, $20);
+    `, req.ID, req.Domain, req.URL, req.CanonicalURL, req.UserID, req.ExpiresAt, req.PasswordHash, req.MaxClicks,
+		req.RedirectCode, req.Passthrough, targets, req.Sticky, rules, req.ActiveFrom, versions, req.Title, req.Description, req.Tags, time.Now(),
+		req.Exclusive)
 
-	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
-			err = st.conn.QueryRowContext(ctx, `
-				SELECT ID FROM shorteners WHERE URL = $1
-			`, req.URL).Scan(&returnedID)
+	if err == nil {
+		return nil
+	}
 
-			if err != nil {
-				return err
-			}
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || !pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
+		return err
+	}
 
-			err = myerrors.ErrConflictURL
-			req.ID = returnedID
-		} else {
-			return err
-		}
+	if pgErr.ConstraintName == primaryKeyConstraint {
+		return fmt.Errorf("%w for id = %s", myerrors.ErrConflictID, req.ID)
 	}
 
-	return err
-}
+	err = st.conn.QueryRowContext(ctx, `
+		SELECT ID FROM shorteners WHERE domain = $1 AND canonical_url = $2 AND `+liveCondition+`
+	`, req.Domain, req.CanonicalURL).Scan(&returnedID)
 
-func (st *Store) insertShortenerBatch(ctx context.Context, req []*models.SetShortenerBatchRequest) error {
-	tx, err := st.conn.Begin()
 	if err != nil {
 		return err
 	}
 
-	defer tx.Rollback()
+	req.ID = returnedID
+	return myerrors.ErrConflictURL
+}
 
+func (st *Store) insertShortenerBatch(ctx context.Context, tx *sql.Tx, req []*models.SetShortenerBatchRequest) error {
 	stmt, err := tx.PrepareContext(ctx,
 		`INSERT INTO shorteners
-				(ID, URL, created_at)
+				(ID, domain, URL, canonical_url, user_id, title, description, tags, created_at, exclusive)
 				VALUES
-				($1, $2, $3)
-				ON CONFLICT (URL) DO NOTHING
+				($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8, $9, $10)
+				ON CONFLICT DO NOTHING
 				RETURNING ID
 			   `)
 	if err != nil {
@@ -118,15 +202,25 @@ func (st *Store) insertShortenerBatch(ctx context.Context, req []*models.SetShor
 	var (
 		returnedID   string
 		errUniqueURL error
+		errUniqueID  error
 	)
 	for key, r := range req {
-		err = stmt.QueryRowContext(ctx, r.ID, r.URL, time.Now()).Scan(&returnedID)
+		req[key].IDConflict = false
+		err = stmt.QueryRowContext(ctx, r.ID, r.Domain, r.URL, r.CanonicalURL, r.UserID, r.Title, r.Description, r.Tags, time.Now(), r.Exclusive).Scan(&returnedID)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
-				err = st.conn.QueryRowContext(ctx, `
-					SELECT ID FROM shorteners WHERE URL = $1
-				`, r.URL).Scan(&returnedID)
+				// exclusive rows are not in the URL index, so only their ID can be taken
+				if !r.Exclusive {
+					err = tx.QueryRowContext(ctx, `
+						SELECT ID FROM shorteners WHERE domain = $1 AND canonical_url = $2 AND `+liveCondition+`
+					`, r.Domain, r.CanonicalURL).Scan(&returnedID)
+				}
 
+				if errors.Is(err, sql.ErrNoRows) {
+					req[key].IDConflict = true
+					errUniqueID = myerrors.ErrConflictID
+					continue
+				}
 				if err != nil {
 					return err
 				}
@@ -138,42 +232,191 @@ func (st *Store) insertShortenerBatch(ctx context.Context, req []*models.SetShor
 		}
 	}
 
-	err = tx.Commit()
-	if err != nil {
-		return err
+	if errUniqueID != nil {
+		return errUniqueID
 	}
 
 	return errUniqueURL
 }
 
+// SetShortenerBatch inserts the whole batch in one transaction. When some IDs are
+// already taken nothing is stored, the items are marked with IDConflict and ErrConflictID is returned.
 func (st *Store) SetShortenerBatch(ctx context.Context, req []models.SetShortenerBatchRequest) error {
 	const countBatch = 1000
 
+	tx, err := st.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
 	buf := make([]*models.SetShortenerBatchRequest, 0, countBatch)
-	var errUniqueURL error
+	var (
+		errUniqueURL error
+		errUniqueID  error
+	)
+	insert := func() error {
+		err := st.insertShortenerBatch(ctx, tx, buf)
+		buf = buf[:0]
+
+		switch {
+		case errors.Is(err, myerrors.ErrConflictID):
+			errUniqueID = err
+		case errors.Is(err, myerrors.ErrConflictURL):
+			errUniqueURL = err
+		case err != nil:
+			return err
+		}
+		return nil
+	}
+
 	for key := range req {
 		buf = append(buf, &req[key])
 
 		if len(buf) >= countBatch {
-			err := st.insertShortenerBatch(ctx, buf)
-			if err != nil {
-				if errors.Is(err, myerrors.ErrConflictURL) {
-					errUniqueURL = myerrors.ErrConflictURL
-				} else {
-					return err
-				}
+			if err = insert(); err != nil {
+				return err
 			}
-			buf = buf[:0]
 		}
 	}
-	err := st.insertShortenerBatch(ctx, buf)
-	if err != nil {
+	if err = insert(); err != nil {
+		return err
+	}
+
+	if errUniqueID != nil {
+		return errUniqueID
+	}
+
+	if err = tx.Commit(); err != nil {
 		return err
 	}
 
 	return errUniqueURL
 }
 
+func (st *Store) UpdateShortener(ctx context.Context, req *models.UpdateShortenerRequest) error {
+	res, err := st.conn.ExecContext(ctx, `
+		UPDATE shorteners SET URL = $1, canonical_url = $2
+		WHERE domain = $3 AND ID = $4 AND user_id = $5 AND NOT is_deleted
+	`, req.URL, req.CanonicalURL, req.Domain, req.ID, req.UserID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if !errors.As(err, &pgErr) || pgErr.Code != pgerrcode.UniqueViolation {
+			return err
+		}
+
+		err = st.conn.QueryRowContext(ctx, `
+			SELECT ID FROM shorteners WHERE domain = $1 AND canonical_url = $2 AND `+liveCondition+`
+		`, req.Domain, req.CanonicalURL).Scan(&req.ExistingID)
+
+		if err != nil {
+			return err
+		}
+
+		return myerrors.ErrConflictURL
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return newErrGetShortenerNotFound(req.ID)
+	}
+
+	return nil
+}
+
+func (st *Store) ConsumeShortenerClick(ctx context.Context, req *models.ConsumeShortenerClickRequest) error {
+	res, err := st.conn.ExecContext(ctx, `
+		UPDATE shorteners SET clicks = clicks + 1, clicks_left = clicks_left - 1
+		WHERE domain = $1 AND ID = $2 AND (clicks_left IS NULL OR clicks_left > 0)
+	`, req.Domain, req.ID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w for id = %s", myerrors.ErrGetShortenerExhausted, req.ID)
+	}
+
+	return nil
+}
+
+func (st *Store) GetUserShorteners(ctx context.Context, req *models.GetUserShortenersRequest) ([]models.UserShortener, error) {
+	rows, err := st.conn.QueryContext(ctx, `
+		SELECT ID, domain, URL FROM shorteners WHERE user_id = $1 AND NOT is_deleted ORDER BY domain, ID
+	`, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]models.UserShortener, 0)
+	for rows.Next() {
+		var r models.UserShortener
+		if err = rows.Scan(&r.ID, &r.Domain, &r.URL); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (st *Store) DeleteShortenerBatch(ctx context.Context, req []models.DeleteShortenerRequest) error {
+	tx, err := st.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		UPDATE shorteners SET is_deleted = TRUE WHERE domain = $1 AND ID = $2 AND user_id = $3
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, r := range req {
+		if _, err = stmt.ExecContext(ctx, r.Domain, r.ID, r.UserID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (st *Store) NextSequence(ctx context.Context) (uint64, error) {
+	var n int64
+	if err := st.conn.QueryRowContext(ctx, `SELECT nextval('shortener_id_seq')`).Scan(&n); err != nil {
+		return 0, err
+	}
+
+	return uint64(n), nil
+}
+
+func (st *Store) DeleteExpiredShorteners(ctx context.Context, req *models.DeleteExpiredShortenersRequest) error {
+	_, err := st.conn.ExecContext(ctx, `
+		DELETE FROM shorteners WHERE expires_at < $1
+	`, req.ExpiredBefore)
+
+	return err
+}
+
 func (st *Store) Ping(ctx context.Context) error {
 	return st.conn.PingContext(ctx)
 }
@@ -9,6 +9,13 @@ type Repository interface {
 	GetShortener(ctx context.Context, req *models.GetShortenerRequest) (*models.GetShortenerResponse, error)
 	SetShortener(ctx context.Context, req *models.SetShortenerRequest) error
 	SetShortenerBatch(ctx context.Context, req []models.SetShortenerBatchRequest) error
+	UpdateShortener(ctx context.Context, req *models.UpdateShortenerRequest) error
+	ConsumeShortenerClick(ctx context.Context, req *models.ConsumeShortenerClickRequest) error
+	GetUserShorteners(ctx context.Context, req *models.GetUserShortenersRequest) ([]models.UserShortener, error)
+	DeleteShortenerBatch(ctx context.Context, req []models.DeleteShortenerRequest) error
+	DeleteExpiredShorteners(ctx context.Context, req *models.DeleteExpiredShortenersRequest) error
+	// NextSequence returns the next value of a counter that is never reset, starting at 0.
+	NextSequence(ctx context.Context) (uint64, error)
 	Close() error
 	Ping(ctx context.Context) error
 }
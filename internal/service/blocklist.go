@@ -0,0 +1,225 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/Evlushin/shorturl/internal/logger"
+	"go.uber.org/zap"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxHashHosts = 5
+	maxHashPaths = 6
+	minHashBytes = 4
+)
+
+// Blocklist is a URLChecker backed by a local file that is reloaded when it changes.
+// Each line holds one entry, empty lines and lines starting with # are skipped:
+//
+//	domain evil.example        the domain and its subdomains
+//	prefix https://evil.example/path
+//	hash   <hex sha256>        of a host/path expression, a prefix of at least 4 bytes is enough
+//
+// Hashes follow Safe Browsing: the expressions are built from the host and its parent
+// domains combined with the full path, with and without the query, and its directories.
+type Blocklist struct {
+	path string
+
+	mux      *sync.RWMutex
+	domains  map[string]struct{}
+	prefixes []string
+	hashes   []string
+	modTime  time.Time
+	size     int64
+}
+
+func NewBlocklist(path string) (*Blocklist, error) {
+	b := &Blocklist{
+		path: path,
+		mux:  &sync.RWMutex{},
+	}
+
+	if _, err := b.reload(); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// reload reads the file again if it changed since the last load.
+func (b *Blocklist) reload() (bool, error) {
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return false, err
+	}
+
+	b.mux.RLock()
+	unchanged := info.ModTime().Equal(b.modTime) && info.Size() == b.size
+	b.mux.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	file, err := os.Open(b.path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	domains := make(map[string]struct{})
+	var prefixes, hashes []string
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		kind, value, _ := strings.Cut(text, " ")
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return false, fmt.Errorf("blocklist %s:%d: empty %s entry", b.path, line, kind)
+		}
+
+		switch kind {
+		case "domain":
+			domains[strings.TrimSuffix(strings.ToLower(value), ".")] = struct{}{}
+		case "prefix":
+			prefixes = append(prefixes, value)
+		case "hash":
+			value = strings.ToLower(value)
+			if decoded, err := hex.DecodeString(value); err != nil || len(decoded) < minHashBytes || len(decoded) > sha256.Size {
+				return false, fmt.Errorf("blocklist %s:%d: invalid hash %s", b.path, line, value)
+			}
+			hashes = append(hashes, value)
+		default:
+			return false, fmt.Errorf("blocklist %s:%d: unknown entry %s", b.path, line, kind)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return false, err
+	}
+
+	b.mux.Lock()
+	b.domains = domains
+	b.prefixes = prefixes
+	b.hashes = hashes
+	b.modTime = info.ModTime()
+	b.size = info.Size()
+	b.mux.Unlock()
+
+	return true, nil
+}
+
+// watch reloads the file every interval until done is closed. A broken file keeps the previous entries.
+func (b *Blocklist) watch(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			reloaded, err := b.reload()
+			if err != nil {
+				logger.Log.Error("failed to reload blocklist", zap.String("path", b.path), zap.Error(err))
+				continue
+			}
+			if reloaded {
+				logger.Log.Info("blocklist reloaded", zap.String("path", b.path))
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+func (b *Blocklist) IsBlocked(ctx context.Context, rawURL string) (bool, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false, nil
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+
+	for _, domain := range parentDomains(host, 0) {
+		if _, ok := b.domains[domain]; ok {
+			return true, nil
+		}
+	}
+
+	for _, prefix := range b.prefixes {
+		if strings.HasPrefix(rawURL, prefix) {
+			return true, nil
+		}
+	}
+
+	if len(b.hashes) > 0 {
+		for _, expr := range hashExpressions(host, u) {
+			sum := sha256.Sum256([]byte(expr))
+			digest := hex.EncodeToString(sum[:])
+			for _, h := range b.hashes {
+				if strings.HasPrefix(digest, h) {
+					return true, nil
+				}
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// parentDomains lists the host and its parent domains down to the second level.
+// A positive limit keeps only that many of them.
+func parentDomains(host string, limit int) []string {
+	res := []string{host}
+	if net.ParseIP(host) != nil {
+		return res
+	}
+
+	labels := strings.Split(host, ".")
+	for i := 1; i < len(labels)-1; i++ {
+		if limit > 0 && len(res) >= limit {
+			break
+		}
+		res = append(res, strings.Join(labels[i:], "."))
+	}
+	return res
+}
+
+func hashExpressions(host string, u *url.URL) []string {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	paths := make([]string, 0, maxHashPaths)
+	if u.RawQuery != "" {
+		paths = append(paths, path+"?"+u.RawQuery)
+	}
+	paths = append(paths, path)
+	for i := 0; i < len(path) && len(paths) < maxHashPaths; i++ {
+		if path[i] == '/' && i+1 < len(path) {
+			paths = append(paths, path[:i+1])
+		}
+	}
+
+	var res []string
+	for _, h := range parentDomains(host, maxHashHosts) {
+		for _, p := range paths {
+			res = append(res, h+p)
+		}
+	}
+	return res
+}
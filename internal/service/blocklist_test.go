@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBlocklist(t *testing.T) {
+	sum := sha256.Sum256([]byte("hashed.example/bad/"))
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte(`# test list
+domain evil.example
+prefix https://good.example/phish
+hash `+hex.EncodeToString(sum[:8])+`
+`), 0644))
+
+	b, err := NewBlocklist(path)
+	require.NoError(t, err)
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{url: "https://evil.example/", want: true},
+		{url: "https://a.b.evil.example/x", want: true},
+		{url: "https://notevil.example/", want: false},
+		{url: "https://good.example/phishing", want: true},
+		{url: "https://good.example/", want: false},
+		{url: "https://www.hashed.example/bad/page.html?q=1", want: true},
+		{url: "https://hashed.example/good/", want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			blocked, err := b.IsBlocked(context.Background(), test.url)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, blocked)
+		})
+	}
+
+	require.NoError(t, os.WriteFile(path, []byte("domain other.example\n"), 0644))
+	reloaded, err := b.reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+
+	blocked, _ := b.IsBlocked(context.Background(), "https://evil.example/")
+	assert.False(t, blocked)
+	blocked, _ = b.IsBlocked(context.Background(), "https://other.example/")
+	assert.True(t, blocked)
+
+	require.NoError(t, os.WriteFile(path, []byte("bogus entry\n"), 0644))
+	_, err = b.reload()
+	assert.Error(t, err)
+	blocked, _ = b.IsBlocked(context.Background(), "https://other.example/")
+	assert.True(t, blocked, "a broken file keeps the previous entries")
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"fmt"
+	"golang.org/x/net/idna"
+	"net"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ftp":   "21",
+}
+
+// canonicalizer brings URLs to one form, so that spellings of the same address
+// are recognized as duplicates. The original URL is still used for redirects.
+type canonicalizer struct {
+	sortQuery   bool
+	stripParams []string
+}
+
+func newCanonicalizer(sortQuery bool, stripParams []string) *canonicalizer {
+	params := make([]string, 0, len(stripParams))
+	for _, p := range stripParams {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			params = append(params, p)
+		}
+	}
+
+	return &canonicalizer{
+		sortQuery:   sortQuery,
+		stripParams: params,
+	}
+}
+
+func (c *canonicalizer) canonicalize(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+
+	host, err := idna.Punycode.ToASCII(strings.ToLower(u.Hostname()))
+	if err != nil {
+		return "", fmt.Errorf("invalid host %s: %w", u.Hostname(), err)
+	}
+	port := u.Port()
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	switch {
+	case port != "":
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		u.Host = "[" + host + "]"
+	default:
+		u.Host = host
+	}
+
+	if u.Host != "" && u.Path == "" {
+		u.Path = "/"
+		u.RawPath = ""
+	}
+
+	u.RawQuery = c.canonicalQuery(u.RawQuery)
+	u.ForceQuery = false
+
+	return u.String(), nil
+}
+
+// canonicalQuery drops the tracking parameters and optionally sorts the rest.
+// Parameters are kept as written, so their encoding is not changed.
+func (c *canonicalizer) canonicalQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	params := make([]string, 0)
+	for _, p := range strings.Split(rawQuery, "&") {
+		if p == "" {
+			continue
+		}
+
+		key, _, _ := strings.Cut(p, "=")
+		if name, err := url.QueryUnescape(key); err == nil {
+			key = name
+		}
+		if c.isStripped(key) {
+			continue
+		}
+
+		params = append(params, p)
+	}
+
+	if c.sortQuery {
+		// values of a repeated parameter keep their order
+		sort.SliceStable(params, func(i, j int) bool {
+			ki, _, _ := strings.Cut(params[i], "=")
+			kj, _, _ := strings.Cut(params[j], "=")
+			return ki < kj
+		})
+	}
+
+	return strings.Join(params, "&")
+}
+
+func (c *canonicalizer) isStripped(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range c.stripParams {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_canonicalizer(t *testing.T) {
+	tests := []struct {
+		name      string
+		sortQuery bool
+		url       string
+		want      string
+	}{
+		{
+			name: "scheme and host case",
+			url:  "HTTP://Example.COM/Path",
+			want: "http://example.com/Path",
+		},
+		{
+			name: "empty path",
+			url:  "https://example.com",
+			want: "https://example.com/",
+		},
+		{
+			name: "default port",
+			url:  "https://example.com:443/a",
+			want: "https://example.com/a",
+		},
+		{
+			name: "other port",
+			url:  "http://example.com:8080/a",
+			want: "http://example.com:8080/a",
+		},
+		{
+			name: "idn",
+			url:  "http://Пример.рф/",
+			want: "http://xn--e1afmkfd.xn--p1ai/",
+		},
+		{
+			name: "ipv6",
+			url:  "http://[::1]:80/a",
+			want: "http://[::1]/a",
+		},
+		{
+			name: "tracking params",
+			url:  "https://example.com/a?b=1&utm_source=x&UTM_Medium=y&a=2",
+			want: "https://example.com/a?b=1&a=2",
+		},
+		{
+			name:      "sorted query",
+			sortQuery: true,
+			url:       "https://example.com/a?b=1&a=3&a=2",
+			want:      "https://example.com/a?a=3&a=2&b=1",
+		},
+		{
+			name: "only tracking params",
+			url:  "https://example.com/?utm_source=x",
+			want: "https://example.com/",
+		},
+		{
+			name: "escaped path",
+			url:  "https://example.com/a%2Fb",
+			want: "https://example.com/a%2Fb",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newCanonicalizer(test.sortQuery, []string{"utm_*"})
+
+			got, err := c.canonicalize(test.url)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/Evlushin/shorturl/internal/logger"
+	"github.com/Evlushin/shorturl/internal/myerrors"
+	"go.uber.org/zap"
+)
+
+const ReasonBlocked = "blocked"
+
+// URLChecker screens target URLs for malicious content. It gets the canonical form of the URL.
+type URLChecker interface {
+	IsBlocked(ctx context.Context, rawURL string) (bool, error)
+}
+
+// AddURLChecker plugs another checker in, targets blocked by any of them are refused.
+// It is meant to be called before the service starts serving requests.
+func (f *Shortener) AddURLChecker(checker URLChecker) {
+	f.checkers = append(f.checkers, checker)
+}
+
+func (f *Shortener) isBlocked(ctx context.Context, canonicalURL string) (bool, error) {
+	for _, checker := range f.checkers {
+		blocked, err := checker.IsBlocked(ctx, canonicalURL)
+		if err != nil {
+			return false, fmt.Errorf("failed to check url: %w", err)
+		}
+		if blocked {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (f *Shortener) checkTarget(ctx context.Context, rawURL string, canonicalURL string) error {
+	blocked, err := f.isBlocked(ctx, canonicalURL)
+	if err != nil {
+		return err
+	}
+
+	if blocked {
+		return &myerrors.URLPolicyError{
+			URLs: []myerrors.URLError{{URL: rawURL, Reason: ReasonBlocked}},
+		}
+	}
+
+	return nil
+}
+
+// checkRedirect tells whether a stored link got blocked after it was created.
+// Checker failures let the redirect through, so that an unavailable checker does not break every link.
+func (f *Shortener) checkRedirect(ctx context.Context, rawURL string) error {
+	if !f.checkOnRedirect || len(f.checkers) == 0 {
+		return nil
+	}
+
+	canonical, err := f.canonicalizer.canonicalize(rawURL)
+	if err != nil {
+		canonical = rawURL
+	}
+
+	blocked, err := f.isBlocked(ctx, canonical)
+	if err != nil {
+		logger.Log.Error("failed to check redirect target", zap.Error(err))
+		return nil
+	}
+
+	if blocked {
+		return fmt.Errorf("%w : URL : %s", myerrors.ErrGetShortenerBlocked, rawURL)
+	}
+
+	return nil
+}
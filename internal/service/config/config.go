@@ -0,0 +1,32 @@
+package config
+
+import "time"
+
+type Config struct {
+	AliasPattern           string
+	ReservedAliases        []string
+	SweepInterval          time.Duration
+	ExpiredRetention       time.Duration
+	PasswordMaxAttempts    int
+	PasswordAttemptsWindow time.Duration
+	IDGenerator            string
+	IDLength               int
+	IDAlphabet             string
+	IDSalt                 string
+	IDWords                int
+	IDSequenceStart        uint64
+	SortQueryParams        bool
+	StripQueryParams       []string
+	AllowedSchemes         []string
+	MaxURLLength           int
+	AllowedDomains         []string
+	DeniedDomains          []string
+	AllowPrivateHosts      bool
+	BaseURL                string
+	AliasDomains           []string
+	ShortDomains           []string
+	BlocklistPath          string
+	BlocklistReload        time.Duration
+	BlocklistOnRedirect    bool
+	GeoIPPath              string
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"github.com/Evlushin/shorturl/internal/logger"
+	"github.com/Evlushin/shorturl/internal/models"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	deleteBatchSize     = 100
+	deleteFlushInterval = time.Second
+	deleteFlushTimeout  = 10 * time.Second
+)
+
+// DeleteShortenerBatch schedules soft deletion of the user's shorteners and returns immediately.
+func (f *Shortener) DeleteShortenerBatch(ctx context.Context, userID string, domain string, ids []string) error {
+	f.deletes.Add(1)
+	go func() {
+		defer f.deletes.Done()
+		f.fanIn(f.generateDeleteRequests(userID, domain, ids))
+	}()
+
+	return nil
+}
+
+func (f *Shortener) generateDeleteRequests(userID string, domain string, ids []string) chan models.DeleteShortenerRequest {
+	ch := make(chan models.DeleteShortenerRequest)
+
+	go func() {
+		defer close(ch)
+
+		for _, id := range ids {
+			ch <- models.DeleteShortenerRequest{ID: id, Domain: domain, UserID: userID}
+		}
+	}()
+
+	return ch
+}
+
+// fanIn hands the requests to flushDeletions, which keeps taking them until Close has waited for all of them.
+func (f *Shortener) fanIn(ch chan models.DeleteShortenerRequest) {
+	for r := range ch {
+		f.deleteCh <- r
+	}
+}
+
+func (f *Shortener) flushDeletions() {
+	defer f.wg.Done()
+
+	ticker := time.NewTicker(deleteFlushInterval)
+	defer ticker.Stop()
+
+	buf := make([]models.DeleteShortenerRequest, 0, deleteBatchSize)
+	flush := func() {
+		if len(buf) == 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), deleteFlushTimeout)
+		defer cancel()
+
+		if err := f.store.DeleteShortenerBatch(ctx, buf); err != nil {
+			logger.Log.Error("failed to delete shorteners", zap.Error(err))
+		}
+		buf = buf[:0]
+	}
+
+	for {
+		select {
+		case r := <-f.deleteCh:
+			buf = append(buf, r)
+			if len(buf) >= deleteBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-f.done:
+			for {
+				select {
+				case r := <-f.deleteCh:
+					buf = append(buf, r)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"github.com/Evlushin/shorturl/internal/myerrors"
+	"github.com/Evlushin/shorturl/internal/service/config"
+	"math/big"
+	"math/bits"
+	"regexp"
+	"strings"
+)
+
+const (
+	GeneratorRandom     = "random"
+	GeneratorSequence   = "sequence"
+	GeneratorObfuscated = "obfuscated"
+	GeneratorWords      = "words"
+	GeneratorHash       = "hash"
+
+	defaultAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+var alphabetPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// IDGenerator makes candidate short IDs. Uniqueness is checked by the caller.
+type IDGenerator interface {
+	NewID(ctx context.Context) (string, error)
+}
+
+// Sequence returns the next value of a counter that survives restarts, starting at 0.
+type Sequence func(ctx context.Context) (uint64, error)
+
+// URLIDGenerator derives IDs from the URL being shortened instead of making them up.
+// Attempt starts at 1 and grows after every collision, so the generator can offer another ID.
+type URLIDGenerator interface {
+	IDForURL(url string, attempt int) (string, error)
+}
+
+func newIDGenerator(cfg config.Config, sequence Sequence) (IDGenerator, error) {
+	alphabet := cfg.IDAlphabet
+	if alphabet == "" {
+		alphabet = defaultAlphabet
+	}
+	if err := validateAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+
+	length := cfg.IDLength
+	if length <= 0 {
+		length = 8
+	}
+
+	switch cfg.IDGenerator {
+	case GeneratorRandom, "":
+		return newRandomGenerator(alphabet, length), nil
+	case GeneratorSequence:
+		return newSequenceGenerator(alphabet, cfg.IDSequenceStart, sequence), nil
+	case GeneratorObfuscated:
+		return newObfuscatedGenerator(alphabet, length, cfg.IDSalt, cfg.IDSequenceStart, sequence)
+	case GeneratorWords:
+		words := cfg.IDWords
+		if words <= 0 {
+			words = 3
+		}
+		return newWordsGenerator(words), nil
+	case GeneratorHash:
+		// without a key anyone could compute the IDs of URLs
+		if cfg.IDSalt == "" {
+			return nil, fmt.Errorf("%s id generator needs an id salt", GeneratorHash)
+		}
+		return newHashGenerator(alphabet, length, cfg.IDSalt), nil
+	default:
+		return nil, fmt.Errorf("unknown id generator: %s", cfg.IDGenerator)
+	}
+}
+
+func validateAlphabet(alphabet string) error {
+	if len(alphabet) < 2 || !alphabetPattern.MatchString(alphabet) {
+		return fmt.Errorf("invalid id alphabet: %s", alphabet)
+	}
+
+	seen := make(map[rune]struct{}, len(alphabet))
+	for _, c := range alphabet {
+		if _, ok := seen[c]; ok {
+			return fmt.Errorf("id alphabet has duplicate character: %c", c)
+		}
+		seen[c] = struct{}{}
+	}
+
+	return nil
+}
+
+// encodeBase converts n to the positional notation of the alphabet.
+// A positive length left-pads the result with the zero digit.
+func encodeBase(n uint64, alphabet string, length int) string {
+	base := uint64(len(alphabet))
+
+	res := make([]byte, 0, 16)
+	for n > 0 {
+		res = append(res, alphabet[n%base])
+		n /= base
+	}
+	for len(res) < length || len(res) == 0 {
+		res = append(res, alphabet[0])
+	}
+
+	for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+		res[i], res[j] = res[j], res[i]
+	}
+
+	return string(res)
+}
+
+type randomGenerator struct {
+	alphabet string
+	length   int
+}
+
+func newRandomGenerator(alphabet string, length int) *randomGenerator {
+	return &randomGenerator{
+		alphabet: alphabet,
+		length:   length,
+	}
+}
+
+func (g *randomGenerator) NewID(ctx context.Context) (string, error) {
+	max := big.NewInt(int64(len(g.alphabet)))
+
+	res := make([]byte, g.length)
+	for i := range res {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		res[i] = g.alphabet[n.Int64()]
+	}
+
+	return string(res), nil
+}
+
+// sequenceGenerator encodes the store sequence, so IDs handed out before a restart are not repeated.
+type sequenceGenerator struct {
+	alphabet string
+	start    uint64
+	sequence Sequence
+}
+
+func newSequenceGenerator(alphabet string, start uint64, sequence Sequence) *sequenceGenerator {
+	return &sequenceGenerator{
+		alphabet: alphabet,
+		start:    start,
+		sequence: sequence,
+	}
+}
+
+func (g *sequenceGenerator) NewID(ctx context.Context) (string, error) {
+	n, err := g.sequence(ctx)
+	if err != nil {
+		return "", err
+	}
+	return encodeBase(g.start+n, g.alphabet, 0), nil
+}
+
+// obfuscatedGenerator maps the store sequence onto fixed-length IDs through a salted
+// bijection, so consecutive links do not get guessable consecutive IDs.
+type obfuscatedGenerator struct {
+	alphabet   string
+	length     int
+	space      uint64
+	multiplier uint64
+	offset     uint64
+	start      uint64
+	sequence   Sequence
+}
+
+func newObfuscatedGenerator(alphabet string, length int, salt string, start uint64, sequence Sequence) (*obfuscatedGenerator, error) {
+	base := uint64(len(alphabet))
+
+	space := uint64(1)
+	for i := 0; i < length; i++ {
+		hi, lo := bits.Mul64(space, base)
+		if hi != 0 {
+			return nil, fmt.Errorf("id length %d is too big for obfuscated ids", length)
+		}
+		space = lo
+	}
+
+	seed := sha256.Sum256([]byte(salt))
+	shuffled := shuffleAlphabet(alphabet, seed)
+
+	multiplier := binary.BigEndian.Uint64(seed[8:16])%space | 1
+	for gcd(multiplier, space) != 1 {
+		multiplier = (multiplier + 2) % space
+	}
+
+	return &obfuscatedGenerator{
+		alphabet:   shuffled,
+		length:     length,
+		space:      space,
+		multiplier: multiplier,
+		offset:     binary.BigEndian.Uint64(seed[16:24]) % space,
+		start:      start,
+		sequence:   sequence,
+	}, nil
+}
+
+func (g *obfuscatedGenerator) NewID(ctx context.Context) (string, error) {
+	n, err := g.sequence(ctx)
+	if err != nil {
+		return "", err
+	}
+	n += g.start
+	if n >= g.space {
+		return "", myerrors.ErrEndRandomStrings
+	}
+
+	hi, lo := bits.Mul64(n, g.multiplier)
+	_, rem := bits.Div64(hi, lo, g.space)
+	rem, carry := bits.Add64(rem, g.offset, 0)
+	if carry != 0 || rem >= g.space {
+		rem -= g.space
+	}
+
+	return encodeBase(rem, g.alphabet, g.length), nil
+}
+
+func shuffleAlphabet(alphabet string, seed [32]byte) string {
+	res := []byte(alphabet)
+	state := binary.BigEndian.Uint64(seed[:8])
+	for i := len(res) - 1; i > 0; i-- {
+		// xorshift64* keeps the shuffle deterministic for a given salt
+		state ^= state >> 12
+		state ^= state << 25
+		state ^= state >> 27
+		j := int((state * 2685821657736338717) % uint64(i+1))
+		res[i], res[j] = res[j], res[i]
+	}
+
+	return string(res)
+}
+
+func gcd(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+type wordsGenerator struct {
+	words int
+}
+
+func newWordsGenerator(words int) *wordsGenerator {
+	return &wordsGenerator{
+		words: words,
+	}
+}
+
+func (g *wordsGenerator) NewID(ctx context.Context) (string, error) {
+	max := big.NewInt(int64(len(idWords)))
+
+	res := make([]string, g.words)
+	for i := range res {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		res[i] = idWords[n.Int64()]
+	}
+
+	return strings.Join(res, "-"), nil
+}
+
+// hashGenerator derives IDs from a keyed hash of the URL, so the same URL always
+// gets the same ID, even against an empty store. Collisions make the ID longer.
+type hashGenerator struct {
+	alphabet string
+	length   int
+	key      []byte
+}
+
+func newHashGenerator(alphabet string, length int, key string) *hashGenerator {
+	return &hashGenerator{
+		alphabet: alphabet,
+		length:   length,
+		key:      []byte(key),
+	}
+}
+
+func (g *hashGenerator) NewID(ctx context.Context) (string, error) {
+	return "", fmt.Errorf("%s id generator needs the url", GeneratorHash)
+}
+
+func (g *hashGenerator) IDForURL(url string, attempt int) (string, error) {
+	mac := hmac.New(sha256.New, g.key)
+	mac.Write([]byte(url))
+	sum := new(big.Int).SetBytes(mac.Sum(nil))
+
+	length := g.length + attempt - 1
+	base := big.NewInt(int64(len(g.alphabet)))
+	mod := new(big.Int)
+
+	res := make([]byte, 0, length)
+	for len(res) < length {
+		if sum.Sign() == 0 {
+			return "", myerrors.ErrEndRandomStrings
+		}
+		sum.DivMod(sum, base, mod)
+		res = append(res, g.alphabet[mod.Int64()])
+	}
+
+	return string(res), nil
+}
+
+// idWords are short, easy to spell words that read well when joined.
+var idWords = []string{
+	"acorn", "amber", "anchor", "apple", "arrow", "aspen", "atlas", "autumn",
+	"badge", "bamboo", "banjo", "basil", "beach", "berry", "birch", "bison",
+	"blaze", "bloom", "brave", "breeze", "brick", "brook", "cabin", "cactus",
+	"camel", "candle", "canyon", "cedar", "chalk", "cherry", "cider", "clover",
+	"cobalt", "comet", "coral", "cotton", "crane", "creek", "crisp", "crown",
+	"daisy", "delta", "denim", "desert", "dingo", "dolphin", "dove", "dragon",
+	"drift", "dune", "eagle", "ember", "falcon", "fern", "fiddle", "fig",
+	"flame", "flint", "forest", "fox", "frost", "galaxy", "garnet", "gecko",
+	"ginger", "glacier", "glade", "granite", "grape", "gravel", "harbor", "hazel",
+	"heron", "hollow", "honey", "husky", "iris", "island", "ivory", "jade",
+	"jasper", "jolly", "juniper", "kayak", "kettle", "kiwi", "koala", "lagoon",
+	"lantern", "lemon", "lilac", "linen", "lotus", "lucky", "lunar", "lynx",
+	"maple", "marble", "meadow", "melon", "mint", "misty", "mocha", "moose",
+	"nectar", "nimble", "noble", "nutmeg", "oasis", "ocean", "olive", "onyx",
+	"orbit", "orchid", "otter", "panda", "pebble", "pepper", "pilot", "pine",
+	"planet", "plum", "polar", "poppy", "prairie", "quartz", "quiet", "quill",
+	"rapid", "raven", "reef", "ripple", "river", "robin", "rocket", "rose",
+	"ruby", "saffron", "sage", "salmon", "sandy", "sapphire", "scarlet", "shadow",
+	"sierra", "silver", "sparrow", "spruce", "starry", "stone", "storm", "sunny",
+	"swift", "tango", "thistle", "thunder", "tiger", "timber", "topaz", "tulip",
+	"tundra", "velvet", "violet", "walnut", "willow", "winter", "wren", "zebra",
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	rootConfig "github.com/Evlushin/shorturl/internal/config"
+	"github.com/Evlushin/shorturl/internal/models"
+	"github.com/Evlushin/shorturl/internal/myerrors"
+	"github.com/Evlushin/shorturl/internal/repository/inmemory"
+	"github.com/Evlushin/shorturl/internal/service/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// testSequence counts from 0 like the stores do.
+func testSequence() Sequence {
+	var n uint64
+	return func(ctx context.Context) (uint64, error) {
+		n++
+		return n - 1, nil
+	}
+}
+
+func Test_newIDGenerator(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		pattern string
+	}{
+		{
+			name:    "random base62",
+			cfg:     config.Config{IDGenerator: GeneratorRandom, IDLength: 8},
+			pattern: `^[A-Za-z0-9]{8}$`,
+		},
+		{
+			name:    "random custom alphabet",
+			cfg:     config.Config{IDGenerator: GeneratorRandom, IDLength: 12, IDAlphabet: "abc"},
+			pattern: `^[abc]{12}$`,
+		},
+		{
+			name:    "sequence",
+			cfg:     config.Config{IDGenerator: GeneratorSequence},
+			pattern: `^[A-Za-z0-9]+$`,
+		},
+		{
+			name:    "obfuscated",
+			cfg:     config.Config{IDGenerator: GeneratorObfuscated, IDLength: 6, IDSalt: "salt"},
+			pattern: `^[A-Za-z0-9]{6}$`,
+		},
+		{
+			name:    "words",
+			cfg:     config.Config{IDGenerator: GeneratorWords, IDWords: 3},
+			pattern: `^[a-z]+-[a-z]+-[a-z]+$`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, err := newIDGenerator(test.cfg, testSequence())
+			require.NoError(t, err)
+
+			pattern := regexp.MustCompile(test.pattern)
+			for i := 0; i < 100; i++ {
+				id, err := g.NewID(context.Background())
+				require.NoError(t, err)
+				assert.Regexp(t, pattern, id)
+			}
+		})
+	}
+}
+
+func Test_newIDGeneratorInvalidConfig(t *testing.T) {
+	for _, cfg := range []config.Config{
+		{IDGenerator: "unknown"},
+		{IDAlphabet: "aa"},
+		{IDAlphabet: "a/b"},
+		{IDGenerator: GeneratorObfuscated, IDLength: 20},
+		{IDGenerator: GeneratorHash},
+	} {
+		_, err := newIDGenerator(cfg, testSequence())
+		assert.Error(t, err)
+	}
+}
+
+func Test_sequenceGenerator(t *testing.T) {
+	g := newSequenceGenerator("0123456789", 98, testSequence())
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		id, err := g.NewID(context.Background())
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	assert.Equal(t, []string{"98", "99", "100"}, ids)
+}
+
+func Test_obfuscatedGenerator(t *testing.T) {
+	const length = 2
+	alphabet := "0123456789"
+
+	g, err := newObfuscatedGenerator(alphabet, length, "salt", 0, testSequence())
+	require.NoError(t, err)
+
+	seen := make(map[string]struct{})
+	for i := 0; i < 100; i++ {
+		id, err := g.NewID(context.Background())
+		require.NoError(t, err)
+		require.Len(t, id, length)
+		seen[id] = struct{}{}
+	}
+	assert.Len(t, seen, 100, "every counter value maps to a distinct id")
+
+	_, err = g.NewID(context.Background())
+	assert.Error(t, err, "id space is exhausted")
+
+	other, err := newObfuscatedGenerator(alphabet, length, "pepper", 0, testSequence())
+	require.NoError(t, err)
+	first, err := other.NewID(context.Background())
+	require.NoError(t, err)
+	second, err := other.NewID(context.Background())
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+}
+
+func Test_hashGenerator(t *testing.T) {
+	g := newHashGenerator(defaultAlphabet, 6, "key")
+
+	first, err := g.IDForURL("https://example.com", 1)
+	require.NoError(t, err)
+	assert.Len(t, first, 6)
+
+	again, err := newHashGenerator(defaultAlphabet, 6, "key").IDForURL("https://example.com", 1)
+	require.NoError(t, err)
+	assert.Equal(t, first, again, "same key and url give the same id")
+
+	longer, err := g.IDForURL("https://example.com", 3)
+	require.NoError(t, err)
+	assert.Len(t, longer, 8)
+	assert.True(t, strings.HasPrefix(longer, first), "a collision extends the id")
+
+	other, err := newHashGenerator(defaultAlphabet, 6, "other").IDForURL("https://example.com", 1)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, other)
+
+	_, err = g.IDForURL("https://example.com", 100)
+	assert.Error(t, err, "hash is too short for the id")
+}
+
+func TestShortener_SetShortenerBatchHashIDs(t *testing.T) {
+	req := []models.RequestBatch{
+		{CorrelationID: "1", OriginalURL: "https://example.com/1"},
+		{CorrelationID: "2", OriginalURL: "https://example.com/2"},
+	}
+
+	var runs [][]models.SetShortenerBatchRequest
+	for i := 0; i < 2; i++ {
+		store, err := inmemory.NewStore(&rootConfig.Config{})
+		require.NoError(t, err)
+
+		s, err := NewShortener(store, config.Config{IDGenerator: GeneratorHash, IDLength: 6, IDSalt: "key"})
+		require.NoError(t, err)
+
+		res, err := s.SetShortenerBatch(context.Background(), "", req)
+		require.NoError(t, err)
+		runs = append(runs, res)
+		s.Close()
+	}
+
+	for i := range req {
+		assert.Equal(t, runs[0][i].ID, runs[1][i].ID, "rerun against an empty store gives the same ids")
+	}
+}
+
+func TestShortener_SetShortenerIDCollision(t *testing.T) {
+	store, err := inmemory.NewStore(&rootConfig.Config{})
+	require.NoError(t, err)
+
+	s, err := NewShortener(store, config.Config{IDGenerator: GeneratorSequence, AliasPattern: `^[A-Z]$`})
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx := context.Background()
+
+	// the alias takes the ID that the sequence hands out next
+	_, err = s.SetShortener(ctx, &models.SetShortenerRequest{URL: "https://example.com/alias", Alias: "A"})
+	require.NoError(t, err)
+
+	res, err := s.SetShortener(ctx, &models.SetShortenerRequest{URL: "https://example.com/generated"})
+	require.NoError(t, err)
+	assert.Equal(t, "B", res.ID)
+
+	_, err = s.SetShortener(ctx, &models.SetShortenerRequest{URL: "https://example.com/next", Alias: "C"})
+	require.NoError(t, err)
+
+	batch, err := s.SetShortenerBatch(ctx, "", []models.RequestBatch{
+		{CorrelationID: "1", OriginalURL: "https://example.com/1"},
+		{CorrelationID: "2", OriginalURL: "https://example.com/2", Alias: "E"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "D", batch[0].ID)
+	assert.Equal(t, "E", batch[1].ID)
+
+	_, err = s.SetShortener(ctx, &models.SetShortenerRequest{URL: "https://example.com/taken", Alias: "B"})
+	assert.ErrorIs(t, err, myerrors.ErrConflictAlias)
+}
+
+func TestShortener_SequenceSurvivesRestart(t *testing.T) {
+	store, err := inmemory.NewStore(&rootConfig.Config{})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	cfg := config.Config{IDGenerator: GeneratorSequence, ReservedAliases: []string{"C"}}
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		s, err := NewShortener(store, cfg)
+		require.NoError(t, err)
+
+		for j := 0; j < 2; j++ {
+			res, err := s.SetShortener(ctx, &models.SetShortenerRequest{URL: fmt.Sprintf("https://example.com/%d/%d", i, j)})
+			require.NoError(t, err)
+			ids = append(ids, res.ID)
+		}
+		s.Close()
+	}
+
+	assert.Equal(t, []string{"A", "B", "D", "E"}, ids, "a restart continues the sequence and reserved IDs are skipped")
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+type ipRange struct {
+	from    netip.Addr
+	to      netip.Addr
+	country string
+}
+
+// geoIP maps client addresses to countries. The database is a CSV file with lines of either
+// "network,country" (e.g. 192.0.2.0/24,DE) or "first address,last address,country".
+type geoIP struct {
+	ranges []ipRange
+}
+
+func loadGeoIP(path string) (*geoIP, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var ranges []ipRange
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		r, err := parseIPRange(record)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		ranges = append(ranges, r)
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].from.Less(ranges[j].from)
+	})
+
+	return &geoIP{ranges: ranges}, nil
+}
+
+func parseIPRange(record []string) (ipRange, error) {
+	var r ipRange
+	switch len(record) {
+	case 2:
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(record[0]))
+		if err != nil {
+			return r, err
+		}
+		prefix = prefix.Masked()
+		r.from, r.to = prefix.Addr(), lastAddr(prefix)
+	case 3:
+		var err error
+		if r.from, err = netip.ParseAddr(strings.TrimSpace(record[0])); err != nil {
+			return r, err
+		}
+		if r.to, err = netip.ParseAddr(strings.TrimSpace(record[1])); err != nil {
+			return r, err
+		}
+		r.from, r.to = r.from.Unmap(), r.to.Unmap()
+		if r.from.Is4() != r.to.Is4() || r.to.Less(r.from) {
+			return r, fmt.Errorf("invalid range %s - %s", r.from, r.to)
+		}
+	default:
+		return r, fmt.Errorf("expected 2 or 3 fields, got %d", len(record))
+	}
+
+	r.country = strings.ToUpper(strings.TrimSpace(record[len(record)-1]))
+	if !countryPattern.MatchString(r.country) {
+		return r, fmt.Errorf("invalid country %q", r.country)
+	}
+
+	return r, nil
+}
+
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(b)*8; bit++ {
+		b[bit/8] |= 0x80 >> (bit % 8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// country returns the country of the address, empty if it is unknown.
+func (g *geoIP) country(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+
+	i := sort.Search(len(g.ranges), func(i int) bool {
+		return addr.Less(g.ranges[i].from)
+	})
+	if i == 0 {
+		return ""
+	}
+	if r := g.ranges[i-1]; !r.to.Less(addr) && r.from.Is4() == addr.Is4() {
+		return r.country
+	}
+
+	return ""
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+type attempts struct {
+	count       int
+	windowStart time.Time
+}
+
+// attemptLimiter counts attempts per key within a fixed window.
+type attemptLimiter struct {
+	mux         *sync.Mutex
+	maxAttempts int
+	window      time.Duration
+	attempts    map[string]*attempts
+}
+
+func newAttemptLimiter(maxAttempts int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		mux:         &sync.Mutex{},
+		maxAttempts: maxAttempts,
+		window:      window,
+		attempts:    make(map[string]*attempts),
+	}
+}
+
+// Reserve counts an attempt unless the limit is reached, checking and counting under one lock
+// so that concurrent attempts cannot all pass the check. A successful attempt is forgotten by Reset.
+func (l *attemptLimiter) Reserve(key string) bool {
+	if l.maxAttempts <= 0 {
+		return true
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	a, ok := l.attempts[key]
+	if !ok || time.Since(a.windowStart) >= l.window {
+		l.attempts[key] = &attempts{
+			count:       1,
+			windowStart: time.Now(),
+		}
+		return true
+	}
+
+	if a.count >= l.maxAttempts {
+		return false
+	}
+	a.count++
+
+	return true
+}
+
+func (l *attemptLimiter) Reset(key string) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	delete(l.attempts, key)
+}
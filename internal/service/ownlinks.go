@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/Evlushin/shorturl/internal/models"
+	"github.com/Evlushin/shorturl/internal/myerrors"
+	"net"
+	"net/url"
+	"strings"
+)
+
+const (
+	ReasonOwnLinkUnavailable = "own_link_unavailable"
+	ReasonRedirectLoop       = "redirect_loop"
+
+	maxOwnLinkDepth = 10
+)
+
+// linkRef identifies a link, IDs are unique within a domain only.
+type linkRef struct {
+	domain string
+	id     string
+}
+
+// newOwnHosts maps the hosts serving our short links to the domain their IDs belong to,
+// so that targets on them can be recognized. Alias domains serve the default domain.
+func newOwnHosts(baseURL string, aliasDomains []string, shortDomains []string) map[string]string {
+	hosts := make(map[string]string, len(aliasDomains)+len(shortDomains)+1)
+	defaultKey := ""
+	if u, err := url.Parse(baseURL); err == nil && u.Host != "" {
+		hosts[normalizeHost(u.Scheme, u.Host)] = ""
+		defaultKey = models.DomainKey(baseURL)
+	}
+	for _, domain := range aliasDomains {
+		domain = strings.TrimSpace(domain)
+		if domain == "" {
+			continue
+		}
+		if u, err := url.Parse(domain); err == nil && u.Host != "" {
+			domain = u.Host
+		}
+		hosts[normalizeHost("", domain)] = ""
+	}
+	for _, domain := range shortDomains {
+		u, err := url.Parse(strings.TrimSpace(domain))
+		if err != nil || u.Host == "" {
+			continue
+		}
+		if key := models.DomainKey(domain); key != defaultKey {
+			hosts[normalizeHost(u.Scheme, u.Host)] = key
+		}
+	}
+
+	return hosts
+}
+
+// normalizeHost lowercases the host and drops the default port of the scheme.
+func normalizeHost(scheme string, host string) string {
+	host = strings.ToLower(host)
+	h, port, err := net.SplitHostPort(host)
+	if err != nil || port != defaultPorts[strings.ToLower(scheme)] {
+		return host
+	}
+
+	if strings.Contains(h, ":") {
+		return "[" + h + "]"
+	}
+	return h
+}
+
+func (f *Shortener) ownDomain(u *url.URL) (string, bool) {
+	domain, ok := f.ownHosts[normalizeHost(u.Scheme, u.Host)]
+	return domain, ok
+}
+
+// resolveOwnLink replaces a target pointing to one of our short links with the URL behind it,
+// so that no chains are stored. Self is the link being saved, it must not appear in the chain.
+// A longer path and a query are passed on like the redirect does, so links without passthrough can not have them.
+// A non-empty reason tells why the target can not be used.
+func (f *Shortener) resolveOwnLink(ctx context.Context, rawURL string, self linkRef) (string, string, error) {
+	visited := make(map[linkRef]struct{}, maxOwnLinkDepth)
+	if self.id != "" {
+		visited[self] = struct{}{}
+	}
+
+	for depth := 0; depth < maxOwnLinkDepth; depth++ {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return rawURL, "", nil
+		}
+		domain, ok := f.ownDomain(u)
+		if !ok {
+			return rawURL, "", nil
+		}
+
+		id, rest, found := strings.Cut(strings.TrimPrefix(u.EscapedPath(), "/"), "/")
+		if !idPattern.MatchString(id) {
+			return "", ReasonOwnLinkUnavailable, nil
+		}
+		link := linkRef{domain: domain, id: id}
+		if _, ok := visited[link]; ok {
+			return "", ReasonRedirectLoop, nil
+		}
+		visited[link] = struct{}{}
+
+		resp, err := f.store.GetShortener(ctx, &models.GetShortenerRequest{ID: id, Domain: domain})
+		if err != nil {
+			if errors.Is(err, myerrors.ErrGetShortenerNotFound) {
+				return "", ReasonOwnLinkUnavailable, nil
+			}
+			return "", "", err
+		}
+
+		// following a protected or gone link would bypass its checks
+		if shortenerStatus(resp) != models.StatusActive || resp.PasswordHash != "" {
+			return "", ReasonOwnLinkUnavailable, nil
+		}
+
+		rawURL = resp.URL
+		if found || u.RawQuery != "" {
+			if !resp.Passthrough {
+				return "", ReasonOwnLinkUnavailable, nil
+			}
+
+			path := ""
+			if found {
+				path = "/" + rest
+			}
+			if rawURL, err = passthroughURL(resp.URL, path, u.RawQuery); err != nil {
+				return "", ReasonOwnLinkUnavailable, nil
+			}
+		}
+	}
+
+	return "", ReasonRedirectLoop, nil
+}
+
+func (f *Shortener) resolveTarget(ctx context.Context, rawURL string, self linkRef) (string, error) {
+	resolved, reason, err := f.resolveOwnLink(ctx, rawURL, self)
+	if err != nil {
+		return "", err
+	}
+
+	if reason != "" {
+		return "", &myerrors.URLPolicyError{
+			URLs: []myerrors.URLError{{URL: rawURL, Reason: reason}},
+		}
+	}
+
+	return resolved, nil
+}
+
+// resolveBatchTargets replaces the batch targets pointing to our short links and reports the unusable ones.
+func (f *Shortener) resolveBatchTargets(ctx context.Context, req []models.RequestBatch) error {
+	var rejected []myerrors.URLError
+	for i, item := range req {
+		resolved, reason, err := f.resolveOwnLink(ctx, item.OriginalURL, linkRef{domain: item.Domain, id: item.Alias})
+		if err != nil {
+			return err
+		}
+
+		if reason != "" {
+			rejected = append(rejected, myerrors.URLError{
+				CorrelationID: item.CorrelationID,
+				URL:           item.OriginalURL,
+				Reason:        reason,
+			})
+			continue
+		}
+		req[i].OriginalURL = resolved
+	}
+
+	if len(rejected) > 0 {
+		return &myerrors.URLPolicyError{URLs: rejected}
+	}
+
+	return nil
+}
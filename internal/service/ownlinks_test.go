@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	rootConfig "github.com/Evlushin/shorturl/internal/config"
+	"github.com/Evlushin/shorturl/internal/models"
+	"github.com/Evlushin/shorturl/internal/myerrors"
+	"github.com/Evlushin/shorturl/internal/repository/inmemory"
+	"github.com/Evlushin/shorturl/internal/service/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestShortener_OwnLinks(t *testing.T) {
+	store, err := inmemory.NewStore(&rootConfig.Config{})
+	require.NoError(t, err)
+
+	s, err := NewShortener(store, config.Config{
+		AliasPattern: `^[A-Za-z0-9_-]{3,64}$`,
+		BaseURL:      "http://localhost:8080",
+		AliasDomains: []string{"sho.rt"},
+	})
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx := context.Background()
+	reason := func(err error) string {
+		var policyErr *myerrors.URLPolicyError
+		require.ErrorAs(t, err, &policyErr)
+		require.Len(t, policyErr.URLs, 1)
+		return policyErr.URLs[0].Reason
+	}
+
+	_, err = s.SetShortener(ctx, &models.SetShortenerRequest{URL: "https://example.com/a", Alias: "first"})
+	require.NoError(t, err)
+
+	req := &models.SetShortenerRequest{URL: "http://LOCALHOST:8080/first", Alias: "second"}
+	_, err = s.SetShortener(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/a", req.URL, "the target is resolved to the underlying link")
+
+	for _, target := range []string{"https://sho.rt/first/more", "https://sho.rt/first?ref=x"} {
+		_, err = s.SetShortener(ctx, &models.SetShortenerRequest{URL: target})
+		assert.Equal(t, ReasonOwnLinkUnavailable, reason(err), "the link does not pass on %s", target)
+	}
+
+	_, err = s.SetShortener(ctx, &models.SetShortenerRequest{URL: "https://example.com/docs?lang=en", Alias: "docs", Passthrough: true})
+	require.NoError(t, err)
+
+	req = &models.SetShortenerRequest{URL: "https://sho.rt/docs/guide%2Fintro?lang=de&ref=x"}
+	_, err = s.SetShortener(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/docs/guide%2Fintro?lang=en&ref=x", req.URL)
+
+	_, err = s.SetShortener(ctx, &models.SetShortenerRequest{URL: "https://sho.rt/missing"})
+	assert.Equal(t, ReasonOwnLinkUnavailable, reason(err))
+
+	_, err = s.SetShortener(ctx, &models.SetShortenerRequest{URL: "https://sho.rt/self", Alias: "self"})
+	assert.Equal(t, ReasonRedirectLoop, reason(err))
+
+	err = s.UpdateShortener(ctx, &models.UpdateShortenerRequest{ID: "first", URL: "https://sho.rt/first"})
+	assert.Equal(t, ReasonRedirectLoop, reason(err))
+
+	_, err = s.SetShortenerBatch(ctx, "", []models.RequestBatch{
+		{CorrelationID: "1", OriginalURL: "https://example.com/b"},
+		{CorrelationID: "2", OriginalURL: "http://localhost:8080/missing"},
+	})
+	var policyErr *myerrors.URLPolicyError
+	require.ErrorAs(t, err, &policyErr)
+	assert.Equal(t, []myerrors.URLError{
+		{CorrelationID: "2", URL: "http://localhost:8080/missing", Reason: ReasonOwnLinkUnavailable},
+	}, policyErr.URLs)
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"fmt"
+	"github.com/Evlushin/shorturl/internal/myerrors"
+	"net/url"
+	"strings"
+)
+
+// passthroughURL appends the escaped path to the target path and merges the query into the target query.
+// Parameters of the target win over the passed ones, so that a link can not be repointed by its visitors.
+func passthroughURL(target string, path string, rawQuery string) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("%w : URL : %s", myerrors.ErrGetShortenerInvalidRequest, target)
+	}
+
+	if path != "" {
+		if err = joinEscapedPath(u, path); err != nil {
+			return "", err
+		}
+	}
+
+	if rawQuery != "" {
+		query, err := url.ParseQuery(rawQuery)
+		if err != nil {
+			return "", fmt.Errorf("%w : query : %s", myerrors.ErrGetShortenerInvalidRequest, rawQuery)
+		}
+		targetQuery := u.Query()
+		for key := range targetQuery {
+			delete(query, key)
+		}
+		if extra := query.Encode(); extra != "" {
+			if u.RawQuery != "" {
+				u.RawQuery += "&"
+			}
+			u.RawQuery += extra
+		}
+	}
+
+	return u.String(), nil
+}
+
+// joinEscapedPath keeps the escaping of the passed path, so that e.g. an encoded slash stays a part of its segment.
+// Dot segments are rejected, they would climb out of the target path once resolved by the client.
+func joinEscapedPath(u *url.URL, path string) error {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	for _, segment := range strings.Split(path, "/") {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			return fmt.Errorf("%w : path : %s", myerrors.ErrGetShortenerInvalidRequest, path)
+		}
+		if unescaped == "." || unescaped == ".." {
+			return fmt.Errorf("%w : path : %s", myerrors.ErrGetShortenerInvalidRequest, path)
+		}
+	}
+
+	joined := strings.TrimSuffix(u.EscapedPath(), "/") + path
+	unescaped, err := url.PathUnescape(joined)
+	if err != nil {
+		return fmt.Errorf("%w : path : %s", myerrors.ErrGetShortenerInvalidRequest, path)
+	}
+	u.Path = unescaped
+	u.RawPath = joined
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"github.com/Evlushin/shorturl/internal/myerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_passthroughURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		path     string
+		rawQuery string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:   "path",
+			target: "https://example.com/base",
+			path:   "/docs/page",
+			want:   "https://example.com/base/docs/page",
+		},
+		{
+			name:   "target with trailing slash",
+			target: "https://example.com/base/",
+			path:   "/docs",
+			want:   "https://example.com/base/docs",
+		},
+		{
+			name:   "target without path",
+			target: "https://example.com",
+			path:   "/docs",
+			want:   "https://example.com/docs",
+		},
+		{
+			name:   "encoded segments",
+			target: "https://example.com/base",
+			path:   "/a%20b/c%2Fd",
+			want:   "https://example.com/base/a%20b/c%2Fd",
+		},
+		{
+			name:   "encoded target",
+			target: "https://example.com/a%2Fb",
+			path:   "/c",
+			want:   "https://example.com/a%2Fb/c",
+		},
+		{
+			name:     "query merged",
+			target:   "https://example.com/base?ref=partner",
+			path:     "/docs",
+			rawQuery: "x=1&ref=visitor",
+			want:     "https://example.com/base/docs?ref=partner&x=1",
+		},
+		{
+			name:     "query only",
+			target:   "https://example.com/base",
+			rawQuery: "x=1&y=a%20b",
+			want:     "https://example.com/base?x=1&y=a+b",
+		},
+		{
+			name:    "dot segment",
+			target:  "https://example.com/base",
+			path:    "/../admin",
+			wantErr: true,
+		},
+		{
+			name:    "encoded dot segment",
+			target:  "https://example.com/base",
+			path:    "/%2e%2E/admin",
+			wantErr: true,
+		},
+		{
+			name:    "invalid escape",
+			target:  "https://example.com/base",
+			path:    "/%zz",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := passthroughURL(test.target, test.path, test.rawQuery)
+			if test.wantErr {
+				assert.ErrorIs(t, err, myerrors.ErrGetShortenerInvalidRequest)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"github.com/Evlushin/shorturl/internal/models"
+	"github.com/Evlushin/shorturl/internal/myerrors"
+	"github.com/Evlushin/shorturl/internal/service/config"
+	"golang.org/x/net/idna"
+	"net"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	ReasonInvalidURL       = "invalid_url"
+	ReasonTooLong          = "too_long"
+	ReasonSchemeNotAllowed = "scheme_not_allowed"
+	ReasonDomainDenied     = "domain_denied"
+	ReasonDomainNotAllowed = "domain_not_allowed"
+	ReasonPrivateAddress   = "private_address"
+)
+
+var defaultSchemes = []string{"http", "https"}
+
+// reservedNetworks are the private ranges the net.IP checks miss: "this network" and the carrier-grade NAT.
+var reservedNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, network, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// urlPolicy decides which target URLs can be shortened. Domain patterns are
+// matched against the whole host, so "*.example.com" covers the subdomains only.
+// Private addresses are recognized by literal IPs and localhost names, hosts are not resolved.
+// Numeric hosts are read like browsers read them, so 2130706433 and 0x7f.1 are 127.0.0.1 too.
+type urlPolicy struct {
+	schemes        map[string]struct{}
+	maxLength      int
+	allowedDomains []string
+	deniedDomains  []string
+	allowPrivate   bool
+}
+
+func newURLPolicy(cfg config.Config) *urlPolicy {
+	schemes := cfg.AllowedSchemes
+	if len(schemes) == 0 {
+		schemes = defaultSchemes
+	}
+
+	p := &urlPolicy{
+		schemes:        make(map[string]struct{}, len(schemes)),
+		maxLength:      cfg.MaxURLLength,
+		allowedDomains: lowerList(cfg.AllowedDomains),
+		deniedDomains:  lowerList(cfg.DeniedDomains),
+		allowPrivate:   cfg.AllowPrivateHosts,
+	}
+	for _, scheme := range schemes {
+		p.schemes[strings.ToLower(scheme)] = struct{}{}
+	}
+
+	return p
+}
+
+func lowerList(list []string) []string {
+	res := make([]string, 0, len(list))
+	for _, v := range list {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// check returns the reason the URL is rejected, or an empty string when it is allowed.
+func (p *urlPolicy) check(rawURL string) string {
+	if p.maxLength > 0 && len(rawURL) > p.maxLength {
+		return ReasonTooLong
+	}
+
+	u, err := url.ParseRequestURI(rawURL)
+	if err != nil || u.Scheme == "" {
+		return ReasonInvalidURL
+	}
+
+	if _, ok := p.schemes[strings.ToLower(u.Scheme)]; !ok {
+		return ReasonSchemeNotAllowed
+	}
+
+	if u.Host == "" {
+		return ReasonInvalidURL
+	}
+
+	host, err := idna.Punycode.ToASCII(strings.TrimSuffix(strings.ToLower(u.Hostname()), "."))
+	if err != nil || host == "" {
+		return ReasonInvalidURL
+	}
+
+	if ip, numeric := parseNumericHost(host); numeric {
+		if ip == nil {
+			return ReasonInvalidURL
+		}
+		host = ip.String()
+	}
+
+	if matchDomain(p.deniedDomains, host) {
+		return ReasonDomainDenied
+	}
+
+	if len(p.allowedDomains) > 0 && !matchDomain(p.allowedDomains, host) {
+		return ReasonDomainNotAllowed
+	}
+
+	if !p.allowPrivate && isPrivateHost(host) {
+		return ReasonPrivateAddress
+	}
+
+	return ""
+}
+
+func matchDomain(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func isPrivateHost(host string) bool {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+		return true
+	}
+
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseNumericHost reads a host ending in a number as an IPv4 address the way inet_aton does:
+// parts may be decimal, octal with a leading 0 or hex with 0x, and the last one fills the remaining bytes.
+// Numeric reports whether the host is meant as an address, the IP is nil when it is not a valid one.
+func parseNumericHost(host string) (net.IP, bool) {
+	parts := strings.Split(host, ".")
+	if strings.Contains(host, ":") || !isNumericPart(parts[len(parts)-1]) {
+		return nil, false
+	}
+	if len(parts) > 4 {
+		return nil, true
+	}
+
+	var addr uint64
+	for i, part := range parts {
+		n, err := parseNumericPart(part)
+		if err != nil {
+			return nil, true
+		}
+
+		if i < len(parts)-1 {
+			if n > 0xff {
+				return nil, true
+			}
+			addr |= n << (8 * (3 - i))
+			continue
+		}
+
+		if n >= 1<<(8*(4-i)) {
+			return nil, true
+		}
+		addr |= n
+	}
+
+	return net.IPv4(byte(addr>>24), byte(addr>>16), byte(addr>>8), byte(addr)), true
+}
+
+func isNumericPart(part string) bool {
+	if lower := strings.ToLower(part); strings.HasPrefix(lower, "0x") {
+		return strings.Trim(lower[2:], "0123456789abcdef") == ""
+	}
+	return part != "" && strings.Trim(part, "0123456789") == ""
+}
+
+func parseNumericPart(part string) (uint64, error) {
+	switch {
+	case strings.HasPrefix(strings.ToLower(part), "0x"):
+		if len(part) == 2 {
+			return 0, nil
+		}
+		return strconv.ParseUint(part[2:], 16, 64)
+	case len(part) > 1 && part[0] == '0':
+		return strconv.ParseUint(part[1:], 8, 64)
+	default:
+		return strconv.ParseUint(part, 10, 64)
+	}
+}
+
+func (f *Shortener) validateURL(rawURL string) error {
+	if reason := f.urlPolicy.check(rawURL); reason != "" {
+		return &myerrors.URLPolicyError{
+			URLs: []myerrors.URLError{{URL: rawURL, Reason: reason}},
+		}
+	}
+
+	return nil
+}
+
+func (f *Shortener) validateBatchURLs(req []models.RequestBatch) error {
+	var rejected []myerrors.URLError
+	for _, item := range req {
+		if reason := f.urlPolicy.check(item.OriginalURL); reason != "" {
+			rejected = append(rejected, myerrors.URLError{
+				CorrelationID: item.CorrelationID,
+				URL:           item.OriginalURL,
+				Reason:        reason,
+			})
+		}
+	}
+
+	if len(rejected) > 0 {
+		return &myerrors.URLPolicyError{URLs: rejected}
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"github.com/Evlushin/shorturl/internal/service/config"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func Test_urlPolicy(t *testing.T) {
+	p := newURLPolicy(config.Config{
+		MaxURLLength:  64,
+		DeniedDomains: []string{"evil.com", "*.evil.com"},
+	})
+	allowList := newURLPolicy(config.Config{
+		AllowedDomains: []string{"example.com", "*.example.com"},
+	})
+
+	tests := []struct {
+		name   string
+		policy *urlPolicy
+		url    string
+		want   string
+	}{
+		{name: "http", policy: p, url: "http://example.com/a", want: ""},
+		{name: "https", policy: p, url: "https://example.com/a", want: ""},
+		{name: "javascript", policy: p, url: "javascript:alert(1)", want: ReasonSchemeNotAllowed},
+		{name: "file", policy: p, url: "file:///etc/passwd", want: ReasonSchemeNotAllowed},
+		{name: "ftp", policy: p, url: "ftp://example.com/a", want: ReasonSchemeNotAllowed},
+		{name: "no host", policy: p, url: "http:///a", want: ReasonInvalidURL},
+		{name: "relative", policy: p, url: "/a/b", want: ReasonInvalidURL},
+		{name: "too long", policy: p, url: "https://example.com/" + strings.Repeat("a", 64), want: ReasonTooLong},
+		{name: "denied domain", policy: p, url: "https://EVIL.com/", want: ReasonDomainDenied},
+		{name: "denied subdomain", policy: p, url: "https://a.b.evil.com/", want: ReasonDomainDenied},
+		{name: "similar domain", policy: p, url: "https://notevil.com/", want: ""},
+		{name: "loopback", policy: p, url: "http://127.0.0.1:8080/", want: ReasonPrivateAddress},
+		{name: "private", policy: p, url: "http://10.1.2.3/", want: ReasonPrivateAddress},
+		{name: "ipv6 loopback", policy: p, url: "http://[::1]/", want: ReasonPrivateAddress},
+		{name: "localhost", policy: p, url: "http://localhost/", want: ReasonPrivateAddress},
+		{name: "decimal loopback", policy: p, url: "http://2130706433/", want: ReasonPrivateAddress},
+		{name: "short loopback", policy: p, url: "http://127.1/", want: ReasonPrivateAddress},
+		{name: "hex loopback", policy: p, url: "http://0x7f.1/", want: ReasonPrivateAddress},
+		{name: "octal private", policy: p, url: "http://012.0.0.1/", want: ReasonPrivateAddress},
+		{name: "carrier-grade nat", policy: p, url: "http://100.64.1.2/", want: ReasonPrivateAddress},
+		{name: "mapped loopback", policy: p, url: "http://[::ffff:127.0.0.1]/", want: ReasonPrivateAddress},
+		{name: "invalid numeric host", policy: p, url: "http://1.2.3.256/", want: ReasonInvalidURL},
+		{name: "numeric public ip", policy: p, url: "http://134744072/", want: ""},
+		{name: "name with numbers", policy: p, url: "http://1.2.3.example/", want: ""},
+		{name: "public ip", policy: p, url: "http://8.8.8.8/", want: ""},
+		{name: "allowed domain", policy: allowList, url: "https://www.example.com/", want: ""},
+		{name: "not allowed domain", policy: allowList, url: "https://example.org/", want: ReasonDomainNotAllowed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, test.policy.check(test.url))
+		})
+	}
+
+	private := newURLPolicy(config.Config{AllowPrivateHosts: true})
+	assert.Empty(t, private.check("http://localhost:8080/"))
+}
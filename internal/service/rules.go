@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/Evlushin/shorturl/internal/models"
+	"github.com/Evlushin/shorturl/internal/myerrors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+
+	maxRules = 32
+)
+
+var (
+	languagePattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{1,8})*$`)
+	countryPattern  = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+// setShortenerRules validates the rules and normalizes their conditions.
+func (f *Shortener) setShortenerRules(ctx context.Context, req *models.SetShortenerRequest) error {
+	if len(req.Rules) > maxRules {
+		return fmt.Errorf("%w : more than %d rules", myerrors.ErrValidateShortenerInvalidRequest, maxRules)
+	}
+
+	rules := make([]models.Rule, 0, len(req.Rules))
+	for _, rule := range req.Rules {
+		rule.Device = strings.ToLower(strings.TrimSpace(rule.Device))
+		rule.Language = strings.ToLower(strings.TrimSpace(rule.Language))
+		rule.Country = strings.ToUpper(strings.TrimSpace(rule.Country))
+
+		switch {
+		case rule.Device == "" && rule.Language == "" && rule.Country == "":
+			return fmt.Errorf("%w : rules : rule without conditions", myerrors.ErrValidateShortenerInvalidRequest)
+		case rule.Device != "" && rule.Device != DeviceMobile && rule.Device != DeviceTablet && rule.Device != DeviceDesktop:
+			return fmt.Errorf("%w : rules : device : %s", myerrors.ErrValidateShortenerInvalidRequest, rule.Device)
+		case rule.Language != "" && !languagePattern.MatchString(rule.Language):
+			return fmt.Errorf("%w : rules : language : %s", myerrors.ErrValidateShortenerInvalidRequest, rule.Language)
+		case rule.Country != "" && !countryPattern.MatchString(rule.Country):
+			return fmt.Errorf("%w : rules : country : %s", myerrors.ErrValidateShortenerInvalidRequest, rule.Country)
+		case rule.Country != "" && f.geoIP == nil:
+			return fmt.Errorf("%w : rules : country rules need a GeoIP database", myerrors.ErrValidateShortenerInvalidRequest)
+		}
+
+		var err error
+		if rule.URL, _, err = f.prepareTarget(ctx, rule.URL, linkRef{domain: req.Domain, id: req.Alias}); err != nil {
+			return err
+		}
+
+		rules = append(rules, rule)
+	}
+	req.Rules = rules
+
+	return nil
+}
+
+// matchRule returns the URL of the first rule the visitor matches.
+func (f *Shortener) matchRule(rules []models.Rule, req *models.GetShortenerRequest) (string, bool) {
+	device := deviceClass(req.UserAgent)
+	language := preferredLanguage(req.AcceptLanguage)
+
+	// the lookup is only worth it for links targeting countries
+	country, countryKnown := "", false
+
+	for _, rule := range rules {
+		if rule.Device != "" && rule.Device != device {
+			continue
+		}
+		if rule.Language != "" && language != rule.Language && !strings.HasPrefix(language, rule.Language+"-") {
+			continue
+		}
+		if rule.Country != "" {
+			if !countryKnown && f.geoIP != nil {
+				country, countryKnown = f.geoIP.country(req.ClientIP), true
+			}
+			if country != rule.Country {
+				continue
+			}
+		}
+
+		return rule.URL, true
+	}
+
+	return "", false
+}
+
+// deviceClass tells the kind of device by its User-Agent, desktop when in doubt.
+func deviceClass(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case strings.Contains(ua, "ipad") || strings.Contains(ua, "tablet") ||
+		strings.Contains(ua, "android") && !strings.Contains(ua, "mobile"):
+		return DeviceTablet
+	case strings.Contains(ua, "mobi") || strings.Contains(ua, "iphone") || strings.Contains(ua, "ipod") ||
+		strings.Contains(ua, "android") || strings.Contains(ua, "windows phone"):
+		return DeviceMobile
+	default:
+		return DeviceDesktop
+	}
+}
+
+// preferredLanguage returns the lowercased tag of the language with the highest quality in the Accept-Language header.
+func preferredLanguage(acceptLanguage string) string {
+	type tag struct {
+		name    string
+		quality float64
+	}
+
+	var tags []tag
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || name == "*" {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if quality > 0 {
+			tags = append(tags, tag{name: name, quality: quality})
+		}
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].quality > tags[j].quality
+	})
+
+	return tags[0].name
+}
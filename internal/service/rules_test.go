@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	rootConfig "github.com/Evlushin/shorturl/internal/config"
+	"github.com/Evlushin/shorturl/internal/models"
+	"github.com/Evlushin/shorturl/internal/myerrors"
+	"github.com/Evlushin/shorturl/internal/repository/inmemory"
+	"github.com/Evlushin/shorturl/internal/service/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	iPhoneUA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148"
+	androidUA = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/120.0 Mobile Safari/537.36"
+	tabletUA  = "Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 Chrome/120.0 Safari/537.36"
+	desktopUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0 Safari/537.36"
+)
+
+func Test_deviceClass(t *testing.T) {
+	assert.Equal(t, DeviceMobile, deviceClass(iPhoneUA))
+	assert.Equal(t, DeviceMobile, deviceClass(androidUA))
+	assert.Equal(t, DeviceTablet, deviceClass(tabletUA))
+	assert.Equal(t, DeviceDesktop, deviceClass(desktopUA))
+	assert.Equal(t, DeviceDesktop, deviceClass(""))
+}
+
+func Test_preferredLanguage(t *testing.T) {
+	assert.Equal(t, "de-at", preferredLanguage("de-AT,de;q=0.9,en;q=0.5"))
+	assert.Equal(t, "de", preferredLanguage("en;q=0.4, de"))
+	assert.Equal(t, "fr", preferredLanguage("*, fr;q=0.8, en;q=0"))
+	assert.Equal(t, "", preferredLanguage(""))
+}
+
+func Test_geoIP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geoip.csv")
+	require.NoError(t, os.WriteFile(path, []byte(`# network,country
+192.0.2.0/24,de
+198.51.100.10,198.51.100.20,FR
+2001:db8::/32,NL
+`), 0644))
+
+	g, err := loadGeoIP(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "DE", g.country("192.0.2.255"))
+	assert.Equal(t, "FR", g.country("198.51.100.15"))
+	assert.Equal(t, "", g.country("198.51.100.21"))
+	assert.Equal(t, "NL", g.country("2001:db8::1"))
+	assert.Equal(t, "DE", g.country("::ffff:192.0.2.1"))
+	assert.Equal(t, "", g.country("203.0.113.1"))
+	assert.Equal(t, "", g.country("not an ip"))
+}
+
+func TestShortener_Rules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geoip.csv")
+	require.NoError(t, os.WriteFile(path, []byte("192.0.2.0/24,CH\n"), 0644))
+
+	store, err := inmemory.NewStore(&rootConfig.Config{})
+	require.NoError(t, err)
+
+	s, err := NewShortener(store, config.Config{
+		AliasPattern: `^[A-Za-z0-9_-]{3,64}$`,
+		GeoIPPath:    path,
+	})
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx := context.Background()
+	_, err = s.SetShortener(ctx, &models.SetShortenerRequest{
+		URL:   "https://example.com/",
+		Alias: "app",
+		Rules: []models.Rule{
+			{Country: "ch", URL: "https://example.com/ch"},
+			{Device: "Mobile", URL: "https://apps.example.com/"},
+			{Language: "de", URL: "https://example.com/de"},
+		},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		req  models.GetShortenerRequest
+		want string
+	}{
+		{
+			name: "country",
+			req:  models.GetShortenerRequest{UserAgent: iPhoneUA, ClientIP: "192.0.2.7"},
+			want: "https://example.com/ch",
+		},
+		{
+			name: "device",
+			req:  models.GetShortenerRequest{UserAgent: iPhoneUA, AcceptLanguage: "de", ClientIP: "203.0.113.1"},
+			want: "https://apps.example.com/",
+		},
+		{
+			name: "language",
+			req:  models.GetShortenerRequest{UserAgent: desktopUA, AcceptLanguage: "de-DE,en;q=0.5"},
+			want: "https://example.com/de",
+		},
+		{
+			name: "default",
+			req:  models.GetShortenerRequest{UserAgent: desktopUA, AcceptLanguage: "en-US,de;q=0.5"},
+			want: "https://example.com/",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.req.ID = "app"
+			resp, err := s.GetShortener(ctx, &test.req)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, resp.URL)
+		})
+	}
+
+	for _, rule := range []models.Rule{
+		{URL: "https://example.com/none"},
+		{Device: "watch", URL: "https://example.com/watch"},
+		{Language: "german!", URL: "https://example.com/de"},
+		{Country: "DEU", URL: "https://example.com/de"},
+	} {
+		_, err = s.SetShortener(ctx, &models.SetShortenerRequest{URL: "https://example.com/invalid", Rules: []models.Rule{rule}})
+		assert.ErrorIs(t, err, myerrors.ErrValidateShortenerInvalidRequest)
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/Evlushin/shorturl/internal/models"
+	"github.com/Evlushin/shorturl/internal/myerrors"
+	"sort"
+	"time"
+)
+
+const maxVersions = 32
+
+// setShortenerSchedule validates the activation time and the versions, ordering them by their time.
+// The expiry, targets and rules must already be set, versions only change the link URL, so they can not be combined with those.
+func (f *Shortener) setShortenerSchedule(ctx context.Context, req *models.SetShortenerRequest) error {
+	if req.ActiveFrom != nil && req.ExpiresAt != nil && !req.ActiveFrom.Before(*req.ExpiresAt) {
+		return fmt.Errorf("%w : active_from : %s is not before expiry", myerrors.ErrValidateShortenerInvalidRequest,
+			req.ActiveFrom.Format(time.RFC3339))
+	}
+
+	if len(req.Versions) > 0 && (len(req.Targets) > 0 || len(req.Rules) > 0) {
+		return fmt.Errorf("%w : versions can not be combined with targets or rules", myerrors.ErrValidateShortenerInvalidRequest)
+	}
+
+	if len(req.Versions) > maxVersions {
+		return fmt.Errorf("%w : more than %d versions", myerrors.ErrValidateShortenerInvalidRequest, maxVersions)
+	}
+
+	versions := make([]models.Version, 0, len(req.Versions))
+	for _, version := range req.Versions {
+		if version.From.IsZero() {
+			return fmt.Errorf("%w : versions : from is required", myerrors.ErrValidateShortenerInvalidRequest)
+		}
+
+		var err error
+		if version.URL, _, err = f.prepareTarget(ctx, version.URL, linkRef{domain: req.Domain, id: req.Alias}); err != nil {
+			return err
+		}
+
+		versions = append(versions, version)
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].From.Before(versions[j].From)
+	})
+	for i := 1; i < len(versions); i++ {
+		if versions[i].From.Equal(versions[i-1].From) {
+			return fmt.Errorf("%w : versions : more than one from %s", myerrors.ErrValidateShortenerInvalidRequest,
+				versions[i].From.Format(time.RFC3339))
+		}
+	}
+	req.Versions = versions
+
+	return nil
+}
+
+// currentURL returns the URL of the latest version started by now, the link URL before the first one.
+func currentURL(linkURL string, versions []models.Version, now time.Time) string {
+	for i := len(versions) - 1; i >= 0; i-- {
+		if !versions[i].From.After(now) {
+			return versions[i].URL
+		}
+	}
+	return linkURL
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	rootConfig "github.com/Evlushin/shorturl/internal/config"
+	"github.com/Evlushin/shorturl/internal/models"
+	"github.com/Evlushin/shorturl/internal/myerrors"
+	"github.com/Evlushin/shorturl/internal/repository/inmemory"
+	"github.com/Evlushin/shorturl/internal/service/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_currentURL(t *testing.T) {
+	now := time.Now()
+	versions := []models.Version{
+		{From: now.Add(-time.Hour), URL: "https://example.com/teaser"},
+		{From: now, URL: "https://example.com/product"},
+		{From: now.Add(time.Hour), URL: "https://example.com/sale"},
+	}
+
+	assert.Equal(t, "https://example.com/", currentURL("https://example.com/", versions, now.Add(-2*time.Hour)))
+	assert.Equal(t, "https://example.com/teaser", currentURL("https://example.com/", versions, now.Add(-time.Minute)))
+	assert.Equal(t, "https://example.com/product", currentURL("https://example.com/", versions, now))
+	assert.Equal(t, "https://example.com/sale", currentURL("https://example.com/", versions, now.Add(2*time.Hour)))
+	assert.Equal(t, "https://example.com/", currentURL("https://example.com/", nil, now))
+}
+
+func TestShortener_Schedule(t *testing.T) {
+	store, err := inmemory.NewStore(&rootConfig.Config{})
+	require.NoError(t, err)
+
+	s, err := NewShortener(store, config.Config{AliasPattern: `^[A-Za-z0-9_-]{3,64}$`})
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	activeFrom := now.Add(time.Hour)
+	_, err = s.SetShortener(ctx, &models.SetShortenerRequest{URL: "https://example.com/later", Alias: "later", ActiveFrom: &activeFrom})
+	require.NoError(t, err)
+
+	_, err = s.GetShortener(ctx, &models.GetShortenerRequest{ID: "later"})
+	assert.ErrorIs(t, err, myerrors.ErrGetShortenerNotActive)
+
+	info, err := s.GetShortenerInfo(ctx, &models.GetShortenerRequest{ID: "later"})
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusScheduled, info.Status)
+
+	_, err = s.SetShortener(ctx, &models.SetShortenerRequest{
+		URL:   "https://example.com/teaser",
+		Alias: "campaign",
+		Versions: []models.Version{
+			{From: now.Add(time.Hour), URL: "https://example.com/sale"},
+			{From: now.Add(-time.Minute), URL: "https://example.com/product"},
+		},
+	})
+	require.NoError(t, err)
+
+	resp, err := s.GetShortener(ctx, &models.GetShortenerRequest{ID: "campaign"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/product", resp.URL)
+	require.Len(t, resp.Versions, 2)
+	assert.Equal(t, "https://example.com/product", resp.Versions[0].URL, "versions are ordered by time")
+
+	expiresAt := now.Add(time.Minute)
+	for _, req := range []*models.SetShortenerRequest{
+		{URL: "https://example.com/a", ActiveFrom: &activeFrom, ExpiresAt: &expiresAt},
+		{URL: "https://example.com/b", Versions: []models.Version{{URL: "https://example.com/c"}}},
+		{URL: "https://example.com/d", Versions: []models.Version{
+			{From: activeFrom, URL: "https://example.com/e"},
+			{From: activeFrom, URL: "https://example.com/f"},
+		}},
+		{
+			URL:      "https://example.com/g",
+			Versions: []models.Version{{From: activeFrom, URL: "https://example.com/h"}},
+			Targets:  []models.Target{{URL: "https://example.com/i", Weight: 1}},
+		},
+		{
+			URL:      "https://example.com/j",
+			Versions: []models.Version{{From: activeFrom, URL: "https://example.com/k"}},
+			Rules:    []models.Rule{{Device: "mobile", URL: "https://example.com/l"}},
+		},
+	} {
+		_, err = s.SetShortener(ctx, req)
+		assert.ErrorIs(t, err, myerrors.ErrValidateShortenerInvalidRequest)
+	}
+}
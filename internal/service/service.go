@@ -2,25 +2,127 @@ package service
 
 import (
 	"context"
-	"crypto/rand"
 	"errors"
 	"fmt"
 	"github.com/Evlushin/shorturl/internal/models"
 	"github.com/Evlushin/shorturl/internal/myerrors"
 	"github.com/Evlushin/shorturl/internal/repository"
-	"net/url"
+	"github.com/Evlushin/shorturl/internal/service/config"
+	"golang.org/x/crypto/bcrypt"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
+)
+
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+const (
+	maxIDAttempts        = 100
+	maxTitleLength       = 256
+	maxDescriptionLength = 2048
+	maxTags              = 32
+	maxTagLength         = 64
+	// maxTTLSeconds keeps the TTL far below the time.Duration limit of about 292 years
+	maxTTLSeconds = 100 * 365 * 24 * 60 * 60
+
+	defaultAliasPattern = `^[A-Za-z0-9_-]{3,64}$`
 )
 
 type Shortener struct {
-	store repository.Repository
+	store           repository.Repository
+	aliasPattern    *regexp.Regexp
+	reservedAliases map[string]struct{}
+	generator       IDGenerator
+	canonicalizer   *canonicalizer
+	urlPolicy       *urlPolicy
+	ownHosts        map[string]string
+	checkers        []URLChecker
+	checkOnRedirect bool
+	geoIP           *geoIP
+	passwordLimiter *attemptLimiter
+	deleteCh        chan models.DeleteShortenerRequest
+	deletes         *sync.WaitGroup
+	done            chan struct{}
+	wg              *sync.WaitGroup
 }
 
-func NewShortener(store repository.Repository) *Shortener {
-	return &Shortener{
-		store: store,
+func NewShortener(store repository.Repository, cfg config.Config) (*Shortener, error) {
+	pattern := cfg.AliasPattern
+	if pattern == "" {
+		pattern = defaultAliasPattern
+	}
+
+	aliasPattern, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid alias pattern: %w", err)
+	}
+
+	generator, err := newIDGenerator(cfg, store.NextSequence)
+	if err != nil {
+		return nil, err
+	}
+
+	reservedAliases := make(map[string]struct{}, len(cfg.ReservedAliases))
+	for _, alias := range cfg.ReservedAliases {
+		reservedAliases[strings.ToLower(alias)] = struct{}{}
+	}
+
+	f := &Shortener{
+		store:           store,
+		aliasPattern:    aliasPattern,
+		reservedAliases: reservedAliases,
+		generator:       generator,
+		canonicalizer:   newCanonicalizer(cfg.SortQueryParams, cfg.StripQueryParams),
+		urlPolicy:       newURLPolicy(cfg),
+		ownHosts:        newOwnHosts(cfg.BaseURL, cfg.AliasDomains, cfg.ShortDomains),
+		checkOnRedirect: cfg.BlocklistOnRedirect,
+		passwordLimiter: newAttemptLimiter(cfg.PasswordMaxAttempts, cfg.PasswordAttemptsWindow),
+		deleteCh:        make(chan models.DeleteShortenerRequest, deleteBatchSize),
+		deletes:         &sync.WaitGroup{},
+		done:            make(chan struct{}),
+		wg:              &sync.WaitGroup{},
+	}
+
+	if cfg.BlocklistPath != "" {
+		blocklist, err := NewBlocklist(cfg.BlocklistPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load blocklist: %w", err)
+		}
+		f.AddURLChecker(blocklist)
+
+		if cfg.BlocklistReload > 0 {
+			f.wg.Add(1)
+			go func() {
+				defer f.wg.Done()
+				blocklist.watch(cfg.BlocklistReload, f.done)
+			}()
+		}
+	}
+
+	if cfg.GeoIPPath != "" {
+		if f.geoIP, err = loadGeoIP(cfg.GeoIPPath); err != nil {
+			return nil, fmt.Errorf("failed to load GeoIP database: %w", err)
+		}
 	}
+
+	f.wg.Add(1)
+	go f.flushDeletions()
+
+	if cfg.SweepInterval > 0 {
+		f.wg.Add(1)
+		go f.sweepExpired(cfg.SweepInterval, cfg.ExpiredRetention)
+	}
+
+	return f, nil
+}
+
+// Close flushes pending deletions to the store and stops background workers.
+func (f *Shortener) Close() {
+	// the scheduled deletions reach the flusher before it is stopped
+	f.deletes.Wait()
+	close(f.done)
+	f.wg.Wait()
 }
 
 func (f *Shortener) Ping(ctx context.Context) error {
@@ -28,12 +130,13 @@ func (f *Shortener) Ping(ctx context.Context) error {
 }
 
 func (f *Shortener) GetShortener(ctx context.Context, req *models.GetShortenerRequest) (*models.GetShortenerResponse, error) {
-	if err := getShortenerValidateRequest(req); err != nil {
+	if err := f.getShortenerValidateRequest(req); err != nil {
 		return nil, err
 	}
 
 	repositoryResp, err := f.store.GetShortener(ctx, &models.GetShortenerRequest{
-		ID: req.ID,
+		ID:     req.ID,
+		Domain: req.Domain,
 	})
 	if err != nil {
 		if !errors.Is(err, myerrors.ErrGetShortenerNotFound) {
@@ -44,99 +147,345 @@ func (f *Shortener) GetShortener(ctx context.Context, req *models.GetShortenerRe
 	}
 
 	if repositoryResp != nil {
+		switch shortenerStatus(repositoryResp) {
+		case models.StatusDeleted:
+			return nil, fmt.Errorf("gone: %w", myerrors.ErrGetShortenerDeleted)
+		case models.StatusExpired:
+			return nil, fmt.Errorf("gone: %w", myerrors.ErrGetShortenerExpired)
+		case models.StatusExhausted:
+			return nil, fmt.Errorf("gone: %w", myerrors.ErrGetShortenerExhausted)
+		case models.StatusScheduled:
+			return nil, fmt.Errorf("not active: %w", myerrors.ErrGetShortenerNotActive)
+		}
+
+		target, variant := currentURL(repositoryResp.URL, repositoryResp.Versions, time.Now()), ""
+		if ruleURL, ok := f.matchRule(repositoryResp.Rules, req); ok {
+			target = ruleURL
+		} else if len(repositoryResp.Targets) > 0 {
+			var split models.Target
+			if repositoryResp.Sticky {
+				variant = req.Variant
+			}
+			split, variant = selectTarget(repositoryResp.Targets, variant)
+			target = split.URL
+		}
+
+		if req.Path != "" || req.RawQuery != "" {
+			if !repositoryResp.Passthrough {
+				// without passthrough a query is ignored, but a longer path is not this link
+				if req.Path != "" {
+					return nil, fmt.Errorf("not found: %w for id = %s%s", myerrors.ErrGetShortenerNotFound, req.ID, req.Path)
+				}
+			} else if target, err = passthroughURL(target, req.Path, req.RawQuery); err != nil {
+				return nil, err
+			}
+		}
+
+		if err = f.checkRedirect(ctx, target); err != nil {
+			return nil, err
+		}
+
+		if repositoryResp.PasswordHash != "" {
+			if err = f.checkPassword(req, repositoryResp.PasswordHash); err != nil {
+				return nil, err
+			}
+		}
+
+		if !req.Preview {
+			err = f.store.ConsumeShortenerClick(ctx, &models.ConsumeShortenerClickRequest{
+				ID:     req.ID,
+				Domain: req.Domain,
+			})
+			if err != nil {
+				if errors.Is(err, myerrors.ErrGetShortenerExhausted) {
+					return nil, fmt.Errorf("gone: %w", err)
+				}
+				return nil, fmt.Errorf("failed to consume the shortener click: %w", err)
+			}
+		}
+
 		return &models.GetShortenerResponse{
-			URL: repositoryResp.URL,
+			URL:          target,
+			Sticky:       repositoryResp.Sticky,
+			Variant:      variant,
+			Rules:        repositoryResp.Rules,
+			Versions:     repositoryResp.Versions,
+			Title:        repositoryResp.Title,
+			CreatedAt:    repositoryResp.CreatedAt,
+			ExpiresAt:    repositoryResp.ExpiresAt,
+			ClicksLeft:   repositoryResp.ClicksLeft,
+			RedirectCode: repositoryResp.RedirectCode,
 		}, nil
 	}
 
 	return nil, fmt.Errorf("not found: %w", err)
 }
 
-func getShortenerValidateRequest(req *models.GetShortenerRequest) error {
-	validPattern := regexp.MustCompile(`^[A-Za-z0-9]{8}$`)
-	if !validPattern.MatchString(req.ID) {
+func shortenerStatus(resp *models.GetShortenerResponse) string {
+	switch {
+	case resp.IsDeleted:
+		return models.StatusDeleted
+	case resp.ExpiresAt != nil && !time.Now().Before(*resp.ExpiresAt):
+		return models.StatusExpired
+	case resp.ClicksLeft != nil && *resp.ClicksLeft <= 0:
+		return models.StatusExhausted
+	case resp.ActiveFrom != nil && time.Now().Before(*resp.ActiveFrom):
+		return models.StatusScheduled
+	default:
+		return models.StatusActive
+	}
+}
+
+func (f *Shortener) checkPassword(req *models.GetShortenerRequest, passwordHash string) error {
+	if req.Password == "" {
+		return myerrors.ErrPasswordRequired
+	}
+
+	key := req.Domain + "/" + req.ID
+	if !f.passwordLimiter.Reserve(key) {
+		return fmt.Errorf("%w for id = %s", myerrors.ErrTooManyPasswordAttempts, req.ID)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.Password)); err != nil {
+		return fmt.Errorf("%w for id = %s", myerrors.ErrInvalidPassword, req.ID)
+	}
+
+	f.passwordLimiter.Reset(key)
+
+	return nil
+}
+
+func (f *Shortener) getShortenerValidateRequest(req *models.GetShortenerRequest) error {
+	if !idPattern.MatchString(req.ID) && !f.aliasPattern.MatchString(req.ID) {
 		return myerrors.ErrValidateShortenerInvalidRequest
 	}
 
 	return nil
 }
 
-func setShortenerValidateRequest(req *models.SetShortenerRequest) error {
-	_, err := url.ParseRequestURI(req.URL)
+func (f *Shortener) validateAlias(alias string) error {
+	if !f.aliasPattern.MatchString(alias) {
+		return fmt.Errorf("%w : alias : %s", myerrors.ErrValidateShortenerInvalidRequest, alias)
+	}
 
-	if err != nil {
-		return fmt.Errorf("%w : URL : %s", myerrors.ErrValidateShortenerInvalidRequest, req.URL)
+	if _, ok := f.reservedAliases[strings.ToLower(alias)]; ok {
+		return fmt.Errorf("%w : reserved alias : %s", myerrors.ErrValidateShortenerInvalidRequest, alias)
 	}
 
 	return nil
 }
 
-func setShortenerBatchValidateRequest(req []models.RequestBatch) error {
-	notURL := make([]string, 0)
-	for _, item := range req {
-		err := setShortenerValidateRequest(&models.SetShortenerRequest{
-			URL: item.OriginalURL,
-		})
+func (f *Shortener) canonicalURL(rawURL string) (string, error) {
+	canonical, err := f.canonicalizer.canonicalize(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("%w : URL : %s", myerrors.ErrValidateShortenerInvalidRequest, rawURL)
+	}
 
-		if err != nil {
-			notURL = append(notURL, item.OriginalURL)
+	return canonical, nil
+}
+
+// prepareTarget resolves our own short links in a destination, validates it and checks it against the blocklist.
+// It returns the destination with its canonical form.
+func (f *Shortener) prepareTarget(ctx context.Context, rawURL string, self linkRef) (string, string, error) {
+	resolved, err := f.resolveTarget(ctx, rawURL, self)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err = f.validateURL(resolved); err != nil {
+		return "", "", err
+	}
+
+	canonical, err := f.canonicalURL(resolved)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err = f.checkTarget(ctx, resolved, canonical); err != nil {
+		return "", "", err
+	}
+
+	return resolved, canonical, nil
+}
+
+// normalizeMetadata validates the link labels and trims and deduplicates its tags.
+func normalizeMetadata(title string, description string, tags []string) ([]string, error) {
+	if len(title) > maxTitleLength {
+		return nil, fmt.Errorf("%w : title is longer than %d", myerrors.ErrValidateShortenerInvalidRequest, maxTitleLength)
+	}
+
+	if len(description) > maxDescriptionLength {
+		return nil, fmt.Errorf("%w : description is longer than %d", myerrors.ErrValidateShortenerInvalidRequest, maxDescriptionLength)
+	}
+
+	if len(tags) > maxTags {
+		return nil, fmt.Errorf("%w : more than %d tags", myerrors.ErrValidateShortenerInvalidRequest, maxTags)
+	}
+
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	seen := make(map[string]struct{}, len(tags))
+	res := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || len(tag) > maxTagLength {
+			return nil, fmt.Errorf("%w : tag : %q", myerrors.ErrValidateShortenerInvalidRequest, tag)
+		}
+
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		res = append(res, tag)
+	}
+
+	return res, nil
+}
+
+func setShortenerExpiry(req *models.SetShortenerRequest) error {
+	if req.TTLSeconds != nil {
+		if req.ExpiresAt != nil {
+			return fmt.Errorf("%w : expires_at and ttl_seconds are mutually exclusive", myerrors.ErrValidateShortenerInvalidRequest)
+		}
+
+		if *req.TTLSeconds <= 0 || *req.TTLSeconds > maxTTLSeconds {
+			return fmt.Errorf("%w : ttl_seconds : %d", myerrors.ErrValidateShortenerInvalidRequest, *req.TTLSeconds)
 		}
+
+		expiresAt := time.Now().Add(time.Duration(*req.TTLSeconds) * time.Second)
+		req.ExpiresAt = &expiresAt
+		return nil
 	}
 
-	if len(notURL) > 0 {
-		return fmt.Errorf("%w : URLs : %s", myerrors.ErrValidateShortenerInvalidRequest, strings.Join(notURL, ", "))
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("%w : expires_at : %s", myerrors.ErrValidateShortenerInvalidRequest, req.ExpiresAt.Format(time.RFC3339))
 	}
 
 	return nil
 }
 
-func (f *Shortener) generateRandomString(ctx context.Context, length uint8, limit uint16) (string, error) {
-	if limit <= 0 {
-		return "", myerrors.ErrEndRandomStrings
+func (f *Shortener) setShortenerBatchValidateRequest(req []models.RequestBatch) error {
+	if err := f.validateBatchURLs(req); err != nil {
+		return err
 	}
 
-	const (
-		charset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
-	)
+	notAlias := make([]string, 0)
+	for _, item := range req {
+		if item.Alias != "" && f.validateAlias(item.Alias) != nil {
+			notAlias = append(notAlias, item.Alias)
+		}
 
-	b := make([]byte, length)
-	if _, err := rand.Read(b); err != nil {
-		return "", err
+		if _, err := normalizeMetadata(item.Title, item.Description, item.Tags); err != nil {
+			return fmt.Errorf("%w : correlation_id : %s", err, item.CorrelationID)
+		}
 	}
 
-	result := make([]byte, length)
-	for i := uint8(0); i < length; i++ {
-		result[i] = charset[int(b[i])%len(charset)]
+	if len(notAlias) > 0 {
+		return fmt.Errorf("%w : aliases : %s", myerrors.ErrValidateShortenerInvalidRequest, strings.Join(notAlias, ", "))
 	}
 
-	id := string(result)
-	_, err := f.store.GetShortener(ctx, &models.GetShortenerRequest{
-		ID: id,
-	})
-	if err != nil {
-		if errors.Is(err, myerrors.ErrGetShortenerNotFound) {
-			return id, nil
+	return nil
+}
+
+// newID generates an ID, skipping the ones reserved for routes.
+func (f *Shortener) newID(ctx context.Context, url string, attempt int) (string, error) {
+	for ; ; attempt++ {
+		var (
+			id  string
+			err error
+		)
+		if g, ok := f.generator.(URLIDGenerator); ok {
+			id, err = g.IDForURL(url, attempt)
+		} else {
+			id, err = f.generator.NewID(ctx)
+		}
+		if err != nil {
+			return "", err
 		}
 
-		return "", err
+		if _, ok := f.reservedAliases[strings.ToLower(id)]; !ok {
+			return id, nil
+		}
 	}
-
-	return f.generateRandomString(ctx, length, limit-1)
 }
 
 func (f *Shortener) SetShortener(ctx context.Context, req *models.SetShortenerRequest) (*models.SetShortenerResponse, error) {
+	var err error
+	if req.URL, req.CanonicalURL, err = f.prepareTarget(ctx, req.URL, linkRef{domain: req.Domain, id: req.Alias}); err != nil {
+		return nil, err
+	}
 
-	err := setShortenerValidateRequest(req)
-	if err != nil {
+	if err = f.setShortenerTargets(ctx, req); err != nil {
 		return nil, err
 	}
 
-	req.ID, err = f.generateRandomString(ctx, 8, 10000)
-	if err != nil {
+	if err = f.setShortenerRules(ctx, req); err != nil {
+		return nil, err
+	}
+
+	if err = setShortenerExpiry(req); err != nil {
+		return nil, err
+	}
+
+	if err = f.setShortenerSchedule(ctx, req); err != nil {
+		return nil, err
+	}
+
+	if req.Tags, err = normalizeMetadata(req.Title, req.Description, req.Tags); err != nil {
 		return nil, err
 	}
 
-	err = f.store.SetShortener(ctx, req)
+	if req.MaxClicks != nil && *req.MaxClicks <= 0 {
+		return nil, fmt.Errorf("%w : max_clicks : %d", myerrors.ErrValidateShortenerInvalidRequest, *req.MaxClicks)
+	}
+
+	if req.RedirectCode != 0 && !models.IsRedirectCode(req.RedirectCode) {
+		return nil, fmt.Errorf("%w : redirect_code : %d", myerrors.ErrValidateShortenerInvalidRequest, req.RedirectCode)
+	}
+
+	if req.Password != "" {
+		passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+				return nil, fmt.Errorf("%w : password is too long", myerrors.ErrValidateShortenerInvalidRequest)
+			}
+			return nil, err
+		}
+		req.PasswordHash = string(passwordHash)
+	}
+
+	if req.Alias != "" {
+		if err = f.validateAlias(req.Alias); err != nil {
+			return nil, err
+		}
+	}
+
+	// a link with options of its own must not be answered with another link to the same URL
+	req.Exclusive = hasLinkOptions(req)
+
+	// the store rejects taken IDs, so a fresh one is generated until the insert succeeds
+	for attempt := 1; ; attempt++ {
+		if req.Alias != "" {
+			req.ID = req.Alias
+		} else if req.ID, err = f.newID(ctx, req.CanonicalURL, attempt); err != nil {
+			return nil, err
+		}
+
+		err = f.store.SetShortener(ctx, req)
+		if !errors.Is(err, myerrors.ErrConflictID) {
+			break
+		}
+
+		if req.Alias != "" {
+			return nil, fmt.Errorf("%w : alias : %s", myerrors.ErrConflictAlias, req.Alias)
+		}
+
+		if attempt >= maxIDAttempts {
+			return nil, myerrors.ErrEndRandomStrings
+		}
+	}
 	if err != nil && !errors.Is(err, myerrors.ErrConflictURL) {
 		return nil, err
 	}
@@ -146,30 +495,196 @@ func (f *Shortener) SetShortener(ctx context.Context, req *models.SetShortenerRe
 	}, err
 }
 
-func (f *Shortener) SetShortenerBatch(ctx context.Context, req []models.RequestBatch) ([]models.SetShortenerBatchRequest, error) {
-	if err := setShortenerBatchValidateRequest(req); err != nil {
+func hasLinkOptions(req *models.SetShortenerRequest) bool {
+	return req.Alias != "" || req.ExpiresAt != nil || req.PasswordHash != "" || req.MaxClicks != nil ||
+		req.RedirectCode != 0 || req.Passthrough || len(req.Targets) > 0 || len(req.Rules) > 0 || req.ActiveFrom != nil || len(req.Versions) > 0
+}
+
+func (f *Shortener) SetShortenerBatch(ctx context.Context, userID string, req []models.RequestBatch) ([]models.SetShortenerBatchRequest, error) {
+	if err := f.resolveBatchTargets(ctx, req); err != nil {
+		return nil, err
+	}
+
+	if err := f.setShortenerBatchValidateRequest(req); err != nil {
 		return nil, err
 	}
 
-	var r []models.SetShortenerBatchRequest
+	aliases := make(map[linkRef]struct{})
+	var (
+		r        []models.SetShortenerBatchRequest
+		rejected []myerrors.URLError
+	)
 	for _, item := range req {
-		id, err := f.generateRandomString(ctx, 8, 100)
+		canonical, err := f.canonicalURL(item.OriginalURL)
+		if err != nil {
+			return nil, err
+		}
 
+		blocked, err := f.isBlocked(ctx, canonical)
 		if err != nil {
 			return nil, err
 		}
+		if blocked {
+			rejected = append(rejected, myerrors.URLError{
+				CorrelationID: item.CorrelationID,
+				URL:           item.OriginalURL,
+				Reason:        ReasonBlocked,
+			})
+			continue
+		}
+
+		var id string
+		if item.Alias != "" {
+			alias := linkRef{domain: item.Domain, id: item.Alias}
+			if _, ok := aliases[alias]; ok {
+				return nil, fmt.Errorf("%w : alias : %s", myerrors.ErrConflictAlias, item.Alias)
+			}
+			aliases[alias] = struct{}{}
+			id = item.Alias
+		} else {
+			id, err = f.newID(ctx, canonical, 1)
+		}
 
+		if err != nil {
+			return nil, err
+		}
+
+		tags, _ := normalizeMetadata(item.Title, item.Description, item.Tags)
 		r = append(r, models.SetShortenerBatchRequest{
 			CorrelationID: item.CorrelationID,
 			ID:            id,
+			Domain:        item.Domain,
 			URL:           item.OriginalURL,
+			CanonicalURL:  canonical,
+			UserID:        userID,
+			Title:         item.Title,
+			Description:   item.Description,
+			Tags:          tags,
+			Exclusive:     item.Alias != "",
 		})
 	}
 
-	err := f.store.SetShortenerBatch(ctx, r)
+	if len(rejected) > 0 {
+		return nil, &myerrors.URLPolicyError{URLs: rejected}
+	}
+
+	// the store stores nothing when some IDs are taken, so only those are regenerated
+	var err error
+	attempts := make([]int, len(r))
+	for attempt := 1; ; attempt++ {
+		err = f.store.SetShortenerBatch(ctx, r)
+		if !errors.Is(err, myerrors.ErrConflictID) {
+			break
+		}
+
+		if attempt >= maxIDAttempts {
+			return nil, myerrors.ErrEndRandomStrings
+		}
+
+		for i := range r {
+			if !r[i].IDConflict {
+				continue
+			}
+
+			if req[i].Alias != "" {
+				return nil, fmt.Errorf("%w : alias : %s", myerrors.ErrConflictAlias, req[i].Alias)
+			}
+
+			attempts[i]++
+			if r[i].ID, err = f.newID(ctx, r[i].CanonicalURL, attempts[i]+1); err != nil {
+				return nil, err
+			}
+		}
+	}
 	if err != nil && !errors.Is(err, myerrors.ErrConflictURL) {
 		return nil, err
 	}
 
 	return r, err
 }
+
+func (f *Shortener) GetUserShorteners(ctx context.Context, req *models.GetUserShortenersRequest) ([]models.UserShortener, error) {
+	if req.UserID == "" {
+		return nil, myerrors.ErrUnauthorized
+	}
+
+	res, err := f.store.GetUserShorteners(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user shorteners from the store: %w", err)
+	}
+
+	return res, nil
+}
+
+func (f *Shortener) UpdateShortener(ctx context.Context, req *models.UpdateShortenerRequest) error {
+	if err := f.getShortenerValidateRequest(&models.GetShortenerRequest{ID: req.ID}); err != nil {
+		return err
+	}
+
+	var err error
+	if req.URL, req.CanonicalURL, err = f.prepareTarget(ctx, req.URL, linkRef{domain: req.Domain, id: req.ID}); err != nil {
+		return err
+	}
+
+	repositoryResp, err := f.store.GetShortener(ctx, &models.GetShortenerRequest{
+		ID:     req.ID,
+		Domain: req.Domain,
+	})
+	if err != nil {
+		if errors.Is(err, myerrors.ErrGetShortenerNotFound) {
+			return fmt.Errorf("not found: %w", err)
+		}
+		return fmt.Errorf("failed to fetch the shortener result from the store: %w", err)
+	}
+
+	if repositoryResp.IsDeleted {
+		return fmt.Errorf("gone: %w", myerrors.ErrGetShortenerDeleted)
+	}
+
+	if repositoryResp.UserID != req.UserID {
+		return fmt.Errorf("%w : id : %s", myerrors.ErrForbidden, req.ID)
+	}
+
+	// the redirect of a split link always goes to one of its targets, so a new URL would never be used
+	if len(repositoryResp.Targets) > 0 {
+		return fmt.Errorf("%w : url : link %s splits traffic between targets", myerrors.ErrValidateShortenerInvalidRequest, req.ID)
+	}
+
+	// the URL of a versioned link is only used until its first version starts
+	if len(repositoryResp.Versions) > 0 {
+		return fmt.Errorf("%w : url : link %s switches targets on a schedule", myerrors.ErrValidateShortenerInvalidRequest, req.ID)
+	}
+
+	if repositoryResp.URL == req.URL {
+		return nil
+	}
+
+	err = f.store.UpdateShortener(ctx, req)
+	if err != nil && !errors.Is(err, myerrors.ErrConflictURL) {
+		return fmt.Errorf("failed to update the shortener in the store: %w", err)
+	}
+
+	return err
+}
+
+// GetShortenerInfo returns the stored link without resolving it, so no clicks are consumed.
+func (f *Shortener) GetShortenerInfo(ctx context.Context, req *models.GetShortenerRequest) (*models.GetShortenerResponse, error) {
+	if err := f.getShortenerValidateRequest(req); err != nil {
+		return nil, err
+	}
+
+	repositoryResp, err := f.store.GetShortener(ctx, &models.GetShortenerRequest{
+		ID:     req.ID,
+		Domain: req.Domain,
+	})
+	if err != nil {
+		if errors.Is(err, myerrors.ErrGetShortenerNotFound) {
+			return nil, fmt.Errorf("not found: %w", err)
+		}
+		return nil, fmt.Errorf("failed to fetch the shortener result from the store: %w", err)
+	}
+
+	repositoryResp.Status = shortenerStatus(repositoryResp)
+
+	return repositoryResp, nil
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	rootConfig "github.com/Evlushin/shorturl/internal/config"
+	"github.com/Evlushin/shorturl/internal/models"
+	"github.com/Evlushin/shorturl/internal/myerrors"
+	"github.com/Evlushin/shorturl/internal/repository/inmemory"
+	"github.com/Evlushin/shorturl/internal/service/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShortener_SetShortenerSkipsDeadLinks(t *testing.T) {
+	store, err := inmemory.NewStore(&rootConfig.Config{})
+	require.NoError(t, err)
+
+	s, err := NewShortener(store, config.Config{})
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx := context.Background()
+	expiredAt := time.Now().Add(-time.Minute)
+	require.NoError(t, store.SetShortener(ctx, &models.SetShortenerRequest{
+		ID: "expired", URL: "https://example.com/expired", CanonicalURL: "https://example.com/expired", ExpiresAt: &expiredAt,
+	}))
+	require.NoError(t, store.SetShortener(ctx, &models.SetShortenerRequest{
+		ID: "deleted", URL: "https://example.com/deleted", CanonicalURL: "https://example.com/deleted", UserID: "user",
+	}))
+	require.NoError(t, store.DeleteShortenerBatch(ctx, []models.DeleteShortenerRequest{{ID: "deleted", UserID: "user"}}))
+
+	for _, id := range []string{"expired", "deleted"} {
+		resp, err := s.SetShortener(ctx, &models.SetShortenerRequest{URL: "https://example.com/" + id})
+		require.NoError(t, err)
+		assert.NotEqual(t, id, resp.ID)
+
+		link, err := s.GetShortener(ctx, &models.GetShortenerRequest{ID: resp.ID})
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/"+id, link.URL)
+	}
+}
+
+func TestShortener_SetShortenerKeepsLinkOptions(t *testing.T) {
+	store, err := inmemory.NewStore(&rootConfig.Config{})
+	require.NoError(t, err)
+
+	s, err := NewShortener(store, config.Config{})
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx := context.Background()
+	plain, err := s.SetShortener(ctx, &models.SetShortenerRequest{URL: "https://example.com/"})
+	require.NoError(t, err)
+
+	protected, err := s.SetShortener(ctx, &models.SetShortenerRequest{URL: "https://example.com/", Password: "secret"})
+	require.NoError(t, err)
+	assert.NotEqual(t, plain.ID, protected.ID)
+
+	_, err = s.GetShortener(ctx, &models.GetShortenerRequest{ID: protected.ID})
+	assert.ErrorIs(t, err, myerrors.ErrPasswordRequired)
+
+	again, err := s.SetShortener(ctx, &models.SetShortenerRequest{URL: "https://example.com/"})
+	assert.ErrorIs(t, err, myerrors.ErrConflictURL)
+	assert.Equal(t, plain.ID, again.ID)
+}
+
+func TestShortener_DefaultAliasPattern(t *testing.T) {
+	store, err := inmemory.NewStore(&rootConfig.Config{})
+	require.NoError(t, err)
+
+	s, err := NewShortener(store, config.Config{})
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx := context.Background()
+	for _, alias := range []string{"a", "spring sale", "../admin"} {
+		_, err = s.SetShortener(ctx, &models.SetShortenerRequest{URL: "https://example.com/", Alias: alias})
+		assert.ErrorIs(t, err, myerrors.ErrValidateShortenerInvalidRequest, alias)
+	}
+
+	_, err = s.SetShortener(ctx, &models.SetShortenerRequest{URL: "https://example.com/", Alias: "spring-sale"})
+	assert.NoError(t, err)
+}
+
+func TestShortener_PasswordAttemptsConcurrent(t *testing.T) {
+	store, err := inmemory.NewStore(&rootConfig.Config{})
+	require.NoError(t, err)
+
+	const maxAttempts = 3
+	s, err := NewShortener(store, config.Config{PasswordMaxAttempts: maxAttempts, PasswordAttemptsWindow: time.Minute})
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx := context.Background()
+	resp, err := s.SetShortener(ctx, &models.SetShortenerRequest{URL: "https://example.com/", Password: "secret"})
+	require.NoError(t, err)
+
+	const guesses = 20
+	errs := make(chan error, guesses)
+	var wg sync.WaitGroup
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.GetShortener(ctx, &models.GetShortenerRequest{ID: resp.ID, Password: "wrong"})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	invalid := 0
+	for err := range errs {
+		if errors.Is(err, myerrors.ErrInvalidPassword) {
+			invalid++
+		} else {
+			assert.ErrorIs(t, err, myerrors.ErrTooManyPasswordAttempts)
+		}
+	}
+	assert.Equal(t, maxAttempts, invalid)
+}
+
+func TestShortener_SetShortenerTTL(t *testing.T) {
+	store, err := inmemory.NewStore(&rootConfig.Config{})
+	require.NoError(t, err)
+
+	s, err := NewShortener(store, config.Config{})
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx := context.Background()
+	for _, ttl := range []int64{0, -1, maxTTLSeconds + 1, 10_000_000_000} {
+		_, err = s.SetShortener(ctx, &models.SetShortenerRequest{URL: "https://example.com/", TTLSeconds: &ttl})
+		assert.ErrorIs(t, err, myerrors.ErrValidateShortenerInvalidRequest, "ttl %d", ttl)
+	}
+
+	ttl := int64(maxTTLSeconds)
+	req := &models.SetShortenerRequest{URL: "https://example.com/", TTLSeconds: &ttl}
+	_, err = s.SetShortener(ctx, req)
+	require.NoError(t, err)
+	assert.True(t, req.ExpiresAt.After(time.Now().AddDate(99, 0, 0)))
+}
+
+func TestShortener_CloseFlushesDeletions(t *testing.T) {
+	store, err := inmemory.NewStore(&rootConfig.Config{})
+	require.NoError(t, err)
+
+	s, err := NewShortener(store, config.Config{})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	ids := make([]string, 0, 2*deleteBatchSize+1)
+	for i := 0; i < cap(ids); i++ {
+		res, err := s.SetShortener(ctx, &models.SetShortenerRequest{URL: fmt.Sprintf("https://example.com/%d", i), UserID: "user"})
+		require.NoError(t, err)
+		ids = append(ids, res.ID)
+	}
+
+	require.NoError(t, s.DeleteShortenerBatch(ctx, "user", "", ids))
+	s.Close()
+
+	links, err := store.GetUserShorteners(ctx, &models.GetUserShortenersRequest{UserID: "user"})
+	require.NoError(t, err)
+	assert.Empty(t, links, "deletions scheduled before Close are stored")
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/Evlushin/shorturl/internal/models"
+	"github.com/Evlushin/shorturl/internal/myerrors"
+	"math/rand/v2"
+	"strconv"
+)
+
+const (
+	maxTargets      = 16
+	maxTargetWeight = 10000
+)
+
+// setShortenerTargets validates the split destinations and their weights.
+func (f *Shortener) setShortenerTargets(ctx context.Context, req *models.SetShortenerRequest) error {
+	if len(req.Targets) == 0 {
+		if req.Sticky {
+			return fmt.Errorf("%w : sticky without targets", myerrors.ErrValidateShortenerInvalidRequest)
+		}
+		return nil
+	}
+	if len(req.Targets) > maxTargets {
+		return fmt.Errorf("%w : more than %d targets", myerrors.ErrValidateShortenerInvalidRequest, maxTargets)
+	}
+
+	targets := make([]models.Target, len(req.Targets))
+	for i, target := range req.Targets {
+		if target.Weight <= 0 || target.Weight > maxTargetWeight {
+			return fmt.Errorf("%w : targets : weight : %d", myerrors.ErrValidateShortenerInvalidRequest, target.Weight)
+		}
+
+		resolved, _, err := f.prepareTarget(ctx, target.URL, linkRef{domain: req.Domain, id: req.Alias})
+		if err != nil {
+			return err
+		}
+
+		targets[i] = models.Target{URL: resolved, Weight: target.Weight}
+	}
+	req.Targets = targets
+
+	return nil
+}
+
+// selectTarget picks the destination of a split link, keeping the variant the visitor got before if it still exists.
+func selectTarget(targets []models.Target, variant string) (models.Target, string) {
+	if i, err := strconv.Atoi(variant); err == nil && i >= 0 && i < len(targets) {
+		return targets[i], variant
+	}
+
+	total := 0
+	for _, target := range targets {
+		total += target.Weight
+	}
+	i := pickTarget(targets, rand.IntN(total))
+
+	return targets[i], strconv.Itoa(i)
+}
+
+// pickTarget returns the index of the target whose share of the total weight contains n.
+func pickTarget(targets []models.Target, n int) int {
+	for i, target := range targets {
+		if n < target.Weight {
+			return i
+		}
+		n -= target.Weight
+	}
+	return len(targets) - 1
+}
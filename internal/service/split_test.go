@@ -0,0 +1,38 @@
+package service
+
+import (
+	"github.com/Evlushin/shorturl/internal/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_pickTarget(t *testing.T) {
+	targets := []models.Target{
+		{URL: "https://a.example.com/", Weight: 70},
+		{URL: "https://b.example.com/", Weight: 30},
+	}
+
+	counts := make(map[int]int)
+	for n := 0; n < 100; n++ {
+		counts[pickTarget(targets, n)]++
+	}
+
+	assert.Equal(t, map[int]int{0: 70, 1: 30}, counts)
+}
+
+func Test_selectTarget(t *testing.T) {
+	targets := []models.Target{
+		{URL: "https://a.example.com/", Weight: 1},
+		{URL: "https://b.example.com/", Weight: 1},
+	}
+
+	target, variant := selectTarget(targets, "1")
+	assert.Equal(t, targets[1], target)
+	assert.Equal(t, "1", variant)
+
+	for _, stale := range []string{"", "2", "-1", "x"} {
+		target, variant = selectTarget(targets, stale)
+		assert.Contains(t, []string{"0", "1"}, variant)
+		assert.Contains(t, targets, target)
+	}
+}
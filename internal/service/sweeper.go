@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"github.com/Evlushin/shorturl/internal/logger"
+	"github.com/Evlushin/shorturl/internal/models"
+	"go.uber.org/zap"
+	"time"
+)
+
+const sweepTimeout = time.Minute
+
+// sweepExpired periodically purges links that expired more than retention ago.
+func (f *Shortener) sweepExpired(interval time.Duration, retention time.Duration) {
+	defer f.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), sweepTimeout)
+			err := f.store.DeleteExpiredShorteners(ctx, &models.DeleteExpiredShortenersRequest{
+				ExpiredBefore: time.Now().Add(-retention),
+			})
+			cancel()
+
+			if err != nil {
+				logger.Log.Error("failed to purge expired shorteners", zap.Error(err))
+			}
+		case <-f.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS user_id_idx;
+ALTER TABLE shorteners DROP COLUMN IF EXISTS user_id;
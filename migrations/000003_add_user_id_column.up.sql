@@ -0,0 +1,2 @@
+ALTER TABLE shorteners ADD COLUMN IF NOT EXISTS user_id VARCHAR(36);
+CREATE INDEX IF NOT EXISTS user_id_idx ON shorteners (user_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS expires_at_idx;
+ALTER TABLE shorteners DROP COLUMN IF EXISTS expires_at;
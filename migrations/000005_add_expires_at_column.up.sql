@@ -0,0 +1,2 @@
+ALTER TABLE shorteners ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS expires_at_idx ON shorteners (expires_at) WHERE expires_at IS NOT NULL;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS tags_idx;
+ALTER TABLE shorteners DROP COLUMN IF EXISTS tags;
+ALTER TABLE shorteners DROP COLUMN IF EXISTS description;
+ALTER TABLE shorteners DROP COLUMN IF EXISTS title;
@@ -0,0 +1,4 @@
+ALTER TABLE shorteners ADD COLUMN IF NOT EXISTS title TEXT;
+ALTER TABLE shorteners ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE shorteners ADD COLUMN IF NOT EXISTS tags TEXT[];
+CREATE INDEX IF NOT EXISTS tags_idx ON shorteners USING GIN (tags);
@@ -0,0 +1 @@
+ALTER TABLE shorteners ADD COLUMN IF NOT EXISTS clicks BIGINT NOT NULL DEFAULT 0;
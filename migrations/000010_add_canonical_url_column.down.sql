@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS unique_canonical_url_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS unique_url_idx ON shorteners (URL);
+ALTER TABLE shorteners DROP COLUMN IF EXISTS canonical_url;
@@ -0,0 +1,5 @@
+ALTER TABLE shorteners ADD COLUMN IF NOT EXISTS canonical_url TEXT;
+UPDATE shorteners SET canonical_url = URL WHERE canonical_url IS NULL;
+ALTER TABLE shorteners ALTER COLUMN canonical_url SET NOT NULL;
+DROP INDEX IF EXISTS unique_url_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS unique_canonical_url_idx ON shorteners (canonical_url);
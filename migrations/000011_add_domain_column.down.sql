@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS unique_canonical_url_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS unique_canonical_url_idx ON shorteners (canonical_url);
+ALTER TABLE shorteners DROP CONSTRAINT IF EXISTS shorteners_pkey;
+ALTER TABLE shorteners ADD CONSTRAINT shorteners_pkey PRIMARY KEY (ID);
+ALTER TABLE shorteners DROP COLUMN IF EXISTS domain;
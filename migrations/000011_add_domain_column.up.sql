@@ -0,0 +1,5 @@
+ALTER TABLE shorteners ADD COLUMN IF NOT EXISTS domain TEXT NOT NULL DEFAULT '';
+ALTER TABLE shorteners DROP CONSTRAINT IF EXISTS shorteners_pkey;
+ALTER TABLE shorteners ADD CONSTRAINT shorteners_pkey PRIMARY KEY (domain, ID);
+DROP INDEX IF EXISTS unique_canonical_url_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS unique_canonical_url_idx ON shorteners (domain, canonical_url);
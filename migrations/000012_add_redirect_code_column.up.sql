@@ -0,0 +1 @@
+ALTER TABLE shorteners ADD COLUMN IF NOT EXISTS redirect_code SMALLINT;
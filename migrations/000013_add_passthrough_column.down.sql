@@ -0,0 +1 @@
+ALTER TABLE shorteners DROP COLUMN IF EXISTS passthrough;
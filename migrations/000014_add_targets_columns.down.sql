@@ -0,0 +1,2 @@
+ALTER TABLE shorteners DROP COLUMN IF EXISTS sticky;
+ALTER TABLE shorteners DROP COLUMN IF EXISTS targets;
@@ -0,0 +1,2 @@
+ALTER TABLE shorteners ADD COLUMN IF NOT EXISTS targets JSONB;
+ALTER TABLE shorteners ADD COLUMN IF NOT EXISTS sticky BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,2 @@
+ALTER TABLE shorteners DROP COLUMN IF EXISTS versions;
+ALTER TABLE shorteners DROP COLUMN IF EXISTS active_from;
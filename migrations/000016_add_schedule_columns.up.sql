@@ -0,0 +1,2 @@
+ALTER TABLE shorteners ADD COLUMN IF NOT EXISTS active_from TIMESTAMPTZ;
+ALTER TABLE shorteners ADD COLUMN IF NOT EXISTS versions JSONB;
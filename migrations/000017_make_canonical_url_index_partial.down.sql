@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS unique_canonical_url_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS unique_canonical_url_idx ON shorteners (domain, canonical_url);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS unique_canonical_url_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS unique_canonical_url_idx ON shorteners (domain, canonical_url)
+    WHERE NOT is_deleted AND (clicks_left IS NULL OR clicks_left > 0);
+ALTER TABLE shorteners DROP COLUMN IF EXISTS exclusive;
@@ -0,0 +1,7 @@
+ALTER TABLE shorteners ADD COLUMN IF NOT EXISTS exclusive BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE shorteners SET exclusive = TRUE
+WHERE expires_at IS NOT NULL OR password_hash IS NOT NULL OR clicks_left IS NOT NULL OR redirect_code IS NOT NULL
+   OR passthrough OR targets IS NOT NULL OR rules IS NOT NULL OR active_from IS NOT NULL OR versions IS NOT NULL;
+DROP INDEX IF EXISTS unique_canonical_url_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS unique_canonical_url_idx ON shorteners (domain, canonical_url)
+    WHERE NOT is_deleted AND NOT exclusive AND (clicks_left IS NULL OR clicks_left > 0);
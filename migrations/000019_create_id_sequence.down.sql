@@ -0,0 +1 @@
+DROP SEQUENCE IF EXISTS shortener_id_seq;
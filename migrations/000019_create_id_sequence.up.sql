@@ -0,0 +1,3 @@
+CREATE SEQUENCE IF NOT EXISTS shortener_id_seq MINVALUE 0 START WITH 0;
+-- the counter used to restart on every start, so it continues from the number of links rather than from zero
+SELECT setval('shortener_id_seq', (SELECT COUNT(*) FROM shorteners), false);
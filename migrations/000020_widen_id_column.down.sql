@@ -0,0 +1 @@
+ALTER TABLE shorteners ALTER COLUMN ID TYPE VARCHAR(36);
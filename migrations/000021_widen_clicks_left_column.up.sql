@@ -0,0 +1 @@
+ALTER TABLE shorteners ALTER COLUMN clicks_left TYPE BIGINT;